@@ -0,0 +1,252 @@
+package nbtreader
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// tarMagicOffset is the position of the "ustar" magic in the header of a TAR archive.
+const tarMagicOffset = 257
+
+// isTar reports whether data, that starts with buf, is a TAR archive.
+func isTar(buf []byte) bool {
+	return len(buf) >= tarMagicOffset+5 && bytes.Equal(buf[tarMagicOffset:tarMagicOffset+5], []byte("ustar"))
+}
+
+// Archive is a ZIP or TAR archive, like a world backup. The files in it are read directly, without
+// extracting the archive.
+type Archive struct {
+	r    io.ReaderAt
+	size int64
+
+	zip *zip.Reader
+	// compression is the compression of the whole TAR archive, like gzip for .tar.gz files
+	compression Compression
+
+	files   []ArchiveFile
+	entries map[string]archiveEntry
+}
+
+// ArchiveFile describes a file in an [Archive].
+type ArchiveFile struct {
+	// Name is the path of the file in the archive, separated by slashes.
+	Name    string
+	Size    int64
+	ModTime time.Time
+}
+
+// archiveEntry locates a file in an archive.
+type archiveEntry struct {
+	zip *zip.File
+	// offset is the position of the file in the (decompressed) TAR archive
+	offset int64
+	size   int64
+}
+
+// OpenArchive reads the list of files of the ZIP or TAR archive r with the given size. TAR archives
+// may be compressed as a whole, like .tar.gz files. The files are read from r when opened.
+func OpenArchive(r io.ReaderAt, size int64) (*Archive, error) {
+	a := &Archive{r: r, size: size, entries: map[string]archiveEntry{}}
+
+	var header [4]byte
+	n, _ := r.ReadAt(header[:], 0)
+	if detectCompression(header[:n]) == ZIP {
+		zr, err := zip.NewReader(r, size)
+		if err != nil {
+			return nil, fmt.Errorf("archive: %v", err)
+		}
+		a.zip = zr
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			a.add(ArchiveFile{Name: f.Name, Size: int64(f.UncompressedSize64), ModTime: f.Modified}, archiveEntry{zip: f})
+		}
+		return a, nil
+	}
+
+	a.compression = detectCompression(header[:n])
+	stream, err := a.tarStream()
+	if err != nil {
+		return nil, err
+	}
+	counter := &countingReader{r: stream}
+	tr := tar.NewReader(counter)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("archive: %v", err)
+		}
+		if !h.FileInfo().Mode().IsRegular() {
+			continue
+		}
+		a.add(ArchiveFile{Name: h.Name, Size: h.Size, ModTime: h.ModTime}, archiveEntry{offset: counter.n, size: h.Size})
+	}
+	if len(a.files) == 0 && counter.n == 0 {
+		return nil, fmt.Errorf("archive: not a ZIP or TAR archive")
+	}
+	return a, nil
+}
+
+// add adds the file with a cleaned name.
+func (a *Archive) add(f ArchiveFile, e archiveEntry) {
+	f.Name = cleanArchiveName(f.Name)
+	a.files = append(a.files, f)
+	a.entries[f.Name] = e
+}
+
+// cleanArchiveName returns name without a leading "./" or "/", so files can be found by their
+// path relative to the archive root.
+func cleanArchiveName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(name)), "/")
+}
+
+// tarStream returns the decompressed TAR archive from its start.
+func (a *Archive) tarStream() (io.Reader, error) {
+	r := io.NewSectionReader(a.r, 0, a.size)
+	switch a.compression {
+	case NONE:
+		return r, nil
+	case GZIP:
+		gzipReader, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("archive: %v", err)
+		}
+		return gzipReader, nil
+	case ZLIB:
+		zlibReader, err := zlib.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("archive: %v", err)
+		}
+		return zlibReader, nil
+	case LZ4:
+		return newLZ4Reader(r), nil
+	default:
+		return nil, fmt.Errorf("archive: unsupported compression %s", a.compression)
+	}
+}
+
+// Files returns all regular files in the archive, in the order they are stored.
+func (a *Archive) Files() []ArchiveFile {
+	return a.files
+}
+
+// NBTFiles returns the files in the archive, that contain NBT data by their name, see
+// [IsNBTFile].
+func (a *Archive) NBTFiles() []ArchiveFile {
+	var files []ArchiveFile
+	for _, f := range a.files {
+		if IsNBTFile(f.Name) {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// Open opens the file with the given name in the archive. Files in compressed TAR archives are
+// found by decompressing the archive up to the file.
+func (a *Archive) Open(name string) (io.ReadCloser, error) {
+	e, ok := a.entries[cleanArchiveName(name)]
+	if !ok {
+		return nil, fmt.Errorf("archive: file '%s' does not exist", name)
+	}
+	if e.zip != nil {
+		return e.zip.Open()
+	}
+	if a.compression == NONE {
+		return io.NopCloser(io.NewSectionReader(a.r, e.offset, e.size)), nil
+	}
+
+	stream, err := a.tarStream()
+	if err != nil {
+		return nil, err
+	}
+	if _, err = io.CopyN(io.Discard, stream, e.offset); err != nil {
+		return nil, fmt.Errorf("archive: %v", noEOF(err))
+	}
+	return io.NopCloser(io.LimitReader(stream, e.size)), nil
+}
+
+// Walk calls fn for every regular file in the archive, in the order they are stored, with a reader
+// of its data, that is valid until fn returns. Other than opening each file with [Archive.Open], a
+// compressed TAR archive is decompressed only once. Walk stops at the first error returned by fn.
+func (a *Archive) Walk(fn func(file ArchiveFile, r io.Reader) error) error {
+	if a.zip != nil || a.compression == NONE {
+		for _, f := range a.files {
+			r, err := a.Open(f.Name)
+			if err != nil {
+				return err
+			}
+			err = fn(f, r)
+			r.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	stream, err := a.tarStream()
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(stream)
+	// the regular files are listed in the order of their headers
+	for _, f := range a.files {
+		for {
+			h, err := tr.Next()
+			if err != nil {
+				return fmt.Errorf("archive: %v", noEOF(err))
+			}
+			if h.FileInfo().Mode().IsRegular() {
+				break
+			}
+		}
+		if err = fn(f, tr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsNBTFile reports whether a file contains NBT data by its name: .dat, .nbt, .schematic,
+// .litematic and region files.
+func IsNBTFile(name string) bool {
+	switch strings.ToLower(path.Ext(filepath.ToSlash(name))) {
+	case ".dat", ".nbt", ".schematic", ".litematic":
+		return true
+	}
+	return IsRegionFile(name)
+}
+
+// IsRegionFile reports whether a file is a region file by its name: .mca and .mcr.
+func IsRegionFile(name string) bool {
+	switch strings.ToLower(path.Ext(filepath.ToSlash(name))) {
+	case ".mca", ".mcr":
+		return true
+	}
+	return false
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,56 @@
+package nbtreader
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+)
+
+func TestArchiveWalkCompressedTar(t *testing.T) {
+	files := []struct{ name, data string }{
+		{"world/level.dat", "level"},
+		{"world/playerdata/a.dat", "player a"},
+		{"world/playerdata/b.dat", "player b"},
+	}
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	tw.WriteHeader(&tar.Header{Name: "world/", Typeflag: tar.TypeDir, Mode: 0o755})
+	for _, f := range files {
+		tw.WriteHeader(&tar.Header{Name: f.name, Size: int64(len(f.data)), Mode: 0o644})
+		tw.Write([]byte(f.data))
+	}
+	tw.Close()
+	gw.Close()
+
+	a, err := OpenArchive(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := 0
+	err = a.Walk(func(file ArchiveFile, r io.Reader) error {
+		if i >= len(files) {
+			t.Fatalf("unexpected file %s", file.Name)
+		}
+		// the second file is skipped without reading it
+		if i != 1 {
+			data, err := io.ReadAll(r)
+			if err != nil {
+				return err
+			}
+			if file.Name != files[i].name || string(data) != files[i].data {
+				t.Errorf("file %d is %s with %q, want %s with %q", i, file.Name, data, files[i].name, files[i].data)
+			}
+		}
+		i++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i != len(files) {
+		t.Errorf("walked %d files, want %d", i, len(files))
+	}
+}
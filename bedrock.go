@@ -0,0 +1,143 @@
+package nbtreader
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Bedrock Edition stores NBT in little-endian byte order, with strings in standard UTF-8 instead of
+// Modified UTF-8. Its level.dat starts with a header of two little-endian 32 bit integers: the
+// storage version of the world and the length of the following NBT data.
+
+const (
+	levelHeaderSize = 8
+	// defaultStorageVersion is written in the header of level.dat, if no storage version was read
+	// or set
+	defaultStorageVersion = 10
+)
+
+// ByteOrder sets the byte order of numbers and lengths in binary NBT, for reading and writing.
+// Java Edition uses binary.BigEndian, which is the default, and Bedrock Edition
+// binary.LittleEndian. Little-endian data has strings in standard UTF-8, like Bedrock writes them.
+func ByteOrder(order binary.ByteOrder) Option {
+	return func(o *options) {
+		o.order = order
+	}
+}
+
+// BedrockLevel reads and writes Bedrock's level.dat: little-endian NBT with the header of the
+// storage version and the length of the data. [New] keeps the storage version, see
+// [NBT.StorageVersion], and [NBT.Compose] writes it with the length of the composed data.
+func BedrockLevel() Option {
+	return func(o *options) {
+		o.order = binary.LittleEndian
+		o.levelHeader = true
+	}
+}
+
+// byteOrder returns the configured byte order, big-endian by default. Other implementations, like
+// binary.NativeEndian, are mapped to binary.LittleEndian or binary.BigEndian.
+func (o options) byteOrder() binary.ByteOrder {
+	if o.order != nil && o.order.Uint16([]byte{1, 0}) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}
+
+// utf8 reports whether strings are standard UTF-8 instead of Modified UTF-8.
+func (o options) utf8() bool {
+	return o.byteOrder() == binary.LittleEndian
+}
+
+// byteOrder returns the byte order of a decoder or encoder, or big-endian for other readers and
+// writers.
+func byteOrder(rw any) binary.ByteOrder {
+	switch rw := rw.(type) {
+	case *decoder:
+		if rw.order != nil {
+			return rw.order
+		}
+	case *encoder:
+		if rw.order != nil {
+			return rw.order
+		}
+	}
+	return binary.BigEndian
+}
+
+// ByteOrder returns the byte order, that the NBT object was read with and is composed with.
+func (nbt NBT) ByteOrder() binary.ByteOrder {
+	return nbt.opts.byteOrder()
+}
+
+// StorageVersion returns the storage version from the header of a Bedrock level.dat, read with
+// [BedrockLevel], or 0.
+func (nbt NBT) StorageVersion() int32 {
+	return nbt.storageVersion
+}
+
+// SetStorageVersion sets the storage version, that is written in the header of a Bedrock
+// level.dat. By default, the version that was read is written again, or 10 if there is none.
+func (nbt *NBT) SetStorageVersion(version int32) {
+	nbt.storageVersion = version
+}
+
+// Reencode returns a copy of the NBT object with the same root tag, root name, compression and
+// storage version, that is composed to w with the options nbt was read with and opts. The byte
+// order and the level.dat header are reset to Java's format, unless they are given in opts again,
+// so Reencode converts between Java and Bedrock:
+//
+//	bedrock := nbt.Reencode(w, BedrockLevel())
+//	err := bedrock.Compose(NONE)
+//
+// The details kept by [Lossless] are dropped, as the data changes.
+func (nbt *NBT) Reencode(w io.Writer, opts ...Option) *NBT {
+	c := &NBT{
+		w:              w,
+		rw:             bufio.NewReadWriter(nil, bufio.NewWriter(w)),
+		rootName:       nbt.rootName,
+		root:           nbt.root,
+		compression:    nbt.compression,
+		storageVersion: nbt.storageVersion,
+		opts:           nbt.opts,
+	}
+	c.opts.order, c.opts.levelHeader, c.opts.lossless = nil, false, false
+	for _, opt := range opts {
+		opt(&c.opts)
+	}
+	return c
+}
+
+// readLevelHeader reads the header of a Bedrock level.dat and keeps its storage version. The
+// length is not checked, as the data is read up to the end of the root tag anyway.
+func (nbt *NBT) readLevelHeader() error {
+	var header [levelHeaderSize]byte
+	if _, err := io.ReadFull(nbt.rw, header[:]); err != nil {
+		return fmt.Errorf("reading level.dat header: %v", noEOF(err))
+	}
+	nbt.storageVersion = int32(binary.LittleEndian.Uint32(header[:4]))
+	return nil
+}
+
+// composeLevel writes the root tag to w like compose, after the header of a Bedrock level.dat.
+func (nbt *NBT) composeLevel(w io.Writer) error {
+	var buf bytes.Buffer
+	if err := nbt.compose(&buf); err != nil {
+		return err
+	}
+	version := nbt.storageVersion
+	if version == 0 {
+		version = defaultStorageVersion
+	}
+	var header [levelHeaderSize]byte
+	binary.LittleEndian.PutUint32(header[:4], uint32(version))
+	binary.LittleEndian.PutUint32(header[4:], uint32(buf.Len()))
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
@@ -0,0 +1,141 @@
+package nbtreader
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+)
+
+// benchFile returns the uncompressed data of a file in the directory files.
+func benchFile(b *testing.B, name string) []byte {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		b.Fatal(err)
+	}
+	nbt, err := New(bytes.NewReader(data), nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return benchEncode(b, nbt.Root())
+}
+
+// benchChunk returns a tree like a chunk of a world with 24 sections.
+func benchChunk() Compound {
+	sections := List{TagType: Tag_Compound}
+	for y := -4; y < 20; y++ {
+		palette := List{TagType: Tag_Compound}
+		for i := 0; i < 16; i++ {
+			block := Compound{}
+			block.Set("Name", String(fmt.Sprintf("minecraft:block_%d", i)))
+			properties := Compound{}
+			properties.Set("facing", String("north"))
+			properties.Set("waterlogged", String("false"))
+			block.Set("Properties", properties)
+			palette.Elements = append(palette.Elements, block)
+		}
+		data := make(LongArray, 256)
+		for i := range data {
+			data[i] = Long(i * 0x0123456789)
+		}
+		light := make(ByteArray, 2048)
+		for i := range light {
+			light[i] = Byte(i)
+		}
+		states := Compound{}
+		states.Set("palette", palette)
+		states.Set("data", data)
+		section := Compound{}
+		section.Set("Y", Byte(y))
+		section.Set("block_states", states)
+		section.Set("BlockLight", light)
+		section.Set("SkyLight", light)
+		sections.Elements = append(sections.Elements, section)
+	}
+	heightmaps := Compound{}
+	for _, name := range []String{"MOTION_BLOCKING", "OCEAN_FLOOR", "WORLD_SURFACE"} {
+		heightmaps.Set(name, make(LongArray, 37))
+	}
+	chunk := Compound{}
+	chunk.Set("DataVersion", Int(3465))
+	chunk.Set("xPos", Int(0))
+	chunk.Set("zPos", Int(0))
+	chunk.Set("Status", String("minecraft:full"))
+	chunk.Set("sections", sections)
+	chunk.Set("Heightmaps", heightmaps)
+	return chunk
+}
+
+// benchEncode returns the uncompressed NBT data of root.
+func benchEncode(b *testing.B, root NbtTag) []byte {
+	var buf bytes.Buffer
+	nbt, err := FromRoot(root, &buf)
+	if err == nil {
+		err = nbt.Compose(NONE)
+	}
+	if err != nil {
+		b.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func benchmarkDecode(b *testing.B, data []byte) {
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := New(bytes.NewReader(data), nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkEncode(b *testing.B, data []byte) {
+	nbt, err := New(bytes.NewReader(data), io.Discard)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := nbt.Compose(NONE); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkString(b *testing.B, root NbtTag) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = root.String()
+	}
+}
+
+func BenchmarkBigtestDecode(b *testing.B) {
+	benchmarkDecode(b, benchFile(b, "files/bigtest.nbt"))
+}
+
+func BenchmarkBigtestEncode(b *testing.B) {
+	benchmarkEncode(b, benchFile(b, "files/bigtest.nbt"))
+}
+
+func BenchmarkBigtestString(b *testing.B) {
+	nbt, err := New(bytes.NewReader(benchFile(b, "files/bigtest.nbt")), nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	benchmarkString(b, nbt.Root())
+}
+
+func BenchmarkChunkDecode(b *testing.B) {
+	benchmarkDecode(b, benchEncode(b, benchChunk()))
+}
+
+func BenchmarkChunkEncode(b *testing.B) {
+	benchmarkEncode(b, benchEncode(b, benchChunk()))
+}
+
+func BenchmarkChunkString(b *testing.B) {
+	benchmarkString(b, benchChunk())
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Kesuaheli/nbtreader"
+)
+
+func init() {
+	commands["list"] = listCmd
+}
+
+func listCmd(args []string) {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	all := fs.Bool("all", false, "List all files in the archive, not only NBT files.")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s list [flags] <archive>...\n\nLists the NBT files (.dat, .nbt, .schematic, .litematic and region files) in ZIP and TAR archives, like world backups. A file in an archive is read by every command with the address <archive>!<file>, like backup.zip!world/level.dat.\n\nFlags:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		exitCommand(fs, fmt.Errorf("list takes at least one archive"))
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', tabwriter.AlignRight)
+	for _, name := range fs.Args() {
+		a, f, err := openArchive(name)
+		if err != nil {
+			exitCommand(fs, err)
+		}
+		files := a.NBTFiles()
+		if *all {
+			files = a.Files()
+		}
+		for _, file := range files {
+			fmt.Fprintf(w, "%d\t %s\t %s!%s\n", file.Size, file.ModTime.Format("2006-01-02 15:04"), name, file.Name)
+		}
+		f.Close()
+	}
+	w.Flush()
+}
+
+// openArchive opens the ZIP or TAR archive with the given name. The returned file must be closed,
+// after reading the archive.
+func openArchive(name string) (*nbtreader.Archive, *os.File, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	a, err := nbtreader.OpenArchive(f, info.Size())
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return a, f, nil
+}
+
+// openFile opens the file with the given name. A name like backup.zip!world/level.dat opens the
+// file world/level.dat in the archive backup.zip, unless a file with the full name exists.
+func openFile(name string) (io.ReadCloser, error) {
+	archiveName, fileName, ok := strings.Cut(name, "!")
+	if !ok {
+		return os.Open(name)
+	}
+	if _, err := os.Stat(name); err == nil {
+		return os.Open(name)
+	}
+
+	a, f, err := openArchive(archiveName)
+	if err != nil {
+		return nil, err
+	}
+	r, err := a.Open(fileName)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %v", archiveName, err)
+	}
+	return archiveFile{r, f}, nil
+}
+
+// archiveFile is a file in an archive, that closes the archive on Close.
+type archiveFile struct {
+	io.ReadCloser
+	archive *os.File
+}
+
+func (f archiveFile) Close() error {
+	err := f.ReadCloser.Close()
+	if archiveErr := f.archive.Close(); err == nil {
+		err = archiveErr
+	}
+	return err
+}
+
+// isArchive reports whether the file with the given name is a ZIP or TAR archive by its name.
+func isArchive(name string) bool {
+	name = strings.ToLower(name)
+	for _, ext := range []string{".zip", ".tar", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
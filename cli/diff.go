@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Kesuaheli/nbtreader"
+)
+
+const (
+	diffFormatUnified    = "unified"
+	diffFormatSideBySide = "side-by-side"
+	diffFormatJSON       = "json"
+)
+
+func init() {
+	commands["diff"] = diffCmd
+}
+
+func diffCmd(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	format := fs.String("format", diffFormatUnified, "The output format. One of unified, side-by-side or json.")
+	width := fs.Int("width", 80, "The maximum width of a value in the side-by-side format.")
+	textconv := fs.Bool("textconv", false, "Print a single file as line based text, for use as a git textconv filter.")
+	git := fs.Bool("git", false, "Take the seven arguments of a git external diff driver instead of two files.")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %[1]s diff [flags] <old file> <new file>\n       %[1]s diff -textconv <file>\n       %[1]s diff -git <path> <old file> <old hex> <old mode> <new file> <new hex> <new mode>\n\nFlags:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	switch {
+	case *textconv:
+		if fs.NArg() != 1 {
+			exitCommand(fs, fmt.Errorf("-textconv takes exactly one file"))
+		}
+		tag, err := readNBT(fs.Arg(0))
+		if err != nil {
+			exitCommand(fs, err)
+		}
+		if err = writeTextconv(os.Stdout, nbtreader.Path{}, tag); err != nil {
+			exitCommand(fs, err)
+		}
+		return
+	case *git:
+		// git passes path, old-file, old-hex, old-mode, new-file, new-hex, new-mode and for renames
+		// two more arguments, which are ignored.
+		if fs.NArg() < 7 {
+			exitCommand(fs, fmt.Errorf("-git takes at least seven arguments, got %d", fs.NArg()))
+		}
+		path := fs.Arg(0)
+		changes, err := diffFiles(fs.Arg(1), fs.Arg(4))
+		if err != nil {
+			exitCommand(fs, err)
+		}
+		fmt.Printf("diff --git a/%s b/%s\n", path, path)
+		if err = writeUnified(os.Stdout, "a/"+path, "b/"+path, changes); err != nil {
+			exitCommand(fs, err)
+		}
+		// git aborts on a non-zero exit status of the diff driver, so always exit successfully
+		return
+	}
+
+	if fs.NArg() != 2 {
+		exitCommand(fs, fmt.Errorf("diff takes exactly two files, got %d", fs.NArg()))
+	}
+	changes, err := diffFiles(fs.Arg(0), fs.Arg(1))
+	if err != nil {
+		exitCommand(fs, err)
+	}
+
+	switch strings.ToLower(*format) {
+	case diffFormatUnified:
+		err = writeUnified(os.Stdout, fs.Arg(0), fs.Arg(1), changes)
+	case diffFormatSideBySide:
+		err = writeSideBySide(os.Stdout, changes, *width)
+	case diffFormatJSON:
+		err = writeDiffJSON(os.Stdout, changes)
+	default:
+		err = fmt.Errorf("unknown diff format '%s'", *format)
+	}
+	if err != nil {
+		exitCommand(fs, err)
+	}
+
+	// like diff(1), exit with status 1 if the files differ
+	if len(changes) > 0 {
+		os.Exit(1)
+	}
+}
+
+func diffFiles(oldFile, newFile string) ([]nbtreader.Change, error) {
+	a, err := readNBT(oldFile)
+	if err != nil {
+		return nil, err
+	}
+	b, err := readNBT(newFile)
+	if err != nil {
+		return nil, err
+	}
+	return nbtreader.Diff(a, b), nil
+}
+
+func writeUnified(w io.Writer, oldName, newName string, changes []nbtreader.Change) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	if _, err := fmt.Fprintf(w, "--- %s\n+++ %s\n", oldName, newName); err != nil {
+		return err
+	}
+	for _, c := range changes {
+		if _, err := fmt.Fprintf(w, "@@ %s (%s) @@\n", c.Path.Display(), c.Kind); err != nil {
+			return err
+		}
+		if c.Old != nil {
+			if _, err := fmt.Fprintf(w, "-%s\n", snbt(c.Old)); err != nil {
+				return err
+			}
+		}
+		if c.New != nil {
+			if _, err := fmt.Fprintf(w, "+%s\n", snbt(c.New)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func writeSideBySide(w io.Writer, changes []nbtreader.Change, width int) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, c := range changes {
+		var oldValue, newValue, marker string
+		if c.Old != nil {
+			oldValue = truncate(snbt(c.Old), width)
+		}
+		if c.New != nil {
+			newValue = truncate(snbt(c.New), width)
+		}
+		switch c.Kind {
+		case nbtreader.Added:
+			marker = ">"
+		case nbtreader.Removed:
+			marker = "<"
+		default:
+			marker = "|"
+		}
+		if _, err := fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", c.Path.Display(), oldValue, marker, newValue); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
+
+func writeDiffJSON(w io.Writer, changes []nbtreader.Change) error {
+	if changes == nil {
+		changes = []nbtreader.Change{}
+	}
+	out, err := json.MarshalIndent(changes, "", "	")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(out, '\n'))
+	return err
+}
+
+// writeTextconv writes every leaf of the tree as a single line with its full path, so line based
+// diff tools show the location of each change.
+func writeTextconv(w io.Writer, p nbtreader.Path, tag nbtreader.NbtTag) error {
+	switch t := tag.(type) {
+	case nil:
+		return nil
+	case nbtreader.Compound:
+		if len(t) == 0 {
+			break
+		}
+		for _, k := range t.Keys() {
+			if err := writeTextconv(w, p.Key(k), t[k].Value); err != nil {
+				return err
+			}
+		}
+		return nil
+	case nbtreader.List:
+		if len(t.Elements) == 0 {
+			break
+		}
+		for i, entry := range t.Elements {
+			if err := writeTextconv(w, p.Index(i), entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	_, err := fmt.Fprintf(w, "%s: %s\n", p.Display(), snbt(tag))
+	return err
+}
+
+func snbt(tag nbtreader.NbtTag) string {
+	b, err := nbtreader.MarshalSNBT(tag)
+	if err != nil {
+		return fmt.Sprintf("<%v>", err)
+	}
+	return string(b)
+}
+
+func truncate(s string, width int) string {
+	r := []rune(s)
+	if width <= 3 || len(r) <= width {
+		return s
+	}
+	return string(r[:width-3]) + "..."
+}
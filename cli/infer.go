@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Kesuaheli/nbtreader"
+)
+
+func init() {
+	commands["infer"] = inferCmd
+}
+
+func inferCmd(args []string) {
+	flags := flag.NewFlagSet("infer", flag.ExitOnError)
+	format := flags.String("format", "report", "The output format. Either report or schema.")
+	output := flags.String("out", "", "The file to write the output to. If ommitted, output is written to stdout.")
+	verbose := flags.Bool("v", false, "Print every file and chunk read to stderr.")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s infer [flags] <file or directory>...\n\nInfers the structure of all given NBT files. Directories and ZIP or TAR archives are searched for .dat, .nbt and region (.mca) files, where every chunk of a region is read.\n\nFlags:\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		exitCommand(flags, fmt.Errorf("infer takes at least one file or directory"))
+	}
+	if *format != "report" && *format != "schema" {
+		exitCommand(flags, fmt.Errorf("unknown format '%s'", *format))
+	}
+
+	inf := nbtreader.NewInference()
+	add := func(name string, tag nbtreader.NbtTag) {
+		if *verbose {
+			fmt.Fprintln(os.Stderr, name)
+		}
+		inf.Add(tag)
+	}
+	for _, arg := range flags.Args() {
+		err := filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || path != arg && !nbtreader.IsNBTFile(path) {
+				return nil
+			}
+			if path == arg && isArchive(path) {
+				return inferArchive(path, add)
+			}
+			if nbtreader.IsRegionFile(path) {
+				f, err := os.Open(path)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				inferRegion(path, f, add)
+				return nil
+			}
+			tag, err := readNBT(path)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return nil
+			}
+			if tag != nil {
+				add(path, tag)
+			}
+			return nil
+		})
+		if err != nil {
+			exitCommand(flags, err)
+		}
+	}
+
+	var out []byte
+	var err error
+	if *format == "schema" {
+		out, err = json.MarshalIndent(inf.Schema(), "", "	")
+		out = append(out, '\n')
+	} else {
+		var b strings.Builder
+		fmt.Fprintf(&b, "Inferred from %d files\n\n", inf.Trees())
+		err = inf.WriteReport(&b)
+		out = []byte(b.String())
+	}
+	if err != nil {
+		exitCommand(flags, err)
+	}
+	if err = writeOutput(*output, out); err != nil {
+		exitCommand(flags, err)
+	}
+}
+
+// inferArchive adds every NBT file in the archive to the inference.
+func inferArchive(path string, add func(string, nbtreader.NbtTag)) error {
+	a, f, err := openArchive(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return a.Walk(func(file nbtreader.ArchiveFile, r io.Reader) error {
+		if !nbtreader.IsNBTFile(file.Name) {
+			return nil
+		}
+		name := path + "!" + file.Name
+		if nbtreader.IsRegionFile(file.Name) {
+			// regions are read at the offsets of their chunks, so they are read into memory
+			data, err := io.ReadAll(r)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+				return nil
+			}
+			inferRegion(name, bytes.NewReader(data), add)
+			return nil
+		}
+		nbt, err := nbtreader.New(r, nil)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			return nil
+		}
+		add(name, nbt.Root())
+		return nil
+	})
+}
+
+// inferRegion adds every chunk of the region file r to the inference.
+func inferRegion(path string, r io.ReaderAt, add func(string, nbtreader.NbtTag)) {
+	region, err := nbtreader.OpenRegion(r)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+		return
+	}
+	for z := 0; z < 32; z++ {
+		for x := 0; x < 32; x++ {
+			chunk, err := region.Chunk(x, z)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+				continue
+			}
+			if chunk != nil {
+				add(fmt.Sprintf("%s (chunk %d, %d)", path, x, z), chunk.Root())
+			}
+		}
+	}
+}
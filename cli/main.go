@@ -1,10 +1,15 @@
 package main
 
 import (
-	"encoding/json"
+	"bytes"
+	"encoding/binary"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"slices"
+	"sort"
 	"strings"
 
 	"github.com/Kesuaheli/nbtreader"
@@ -12,39 +17,90 @@ import (
 
 type FileType string
 
+// The file types, that are handled by the CLI itself. All other types are the formats of the
+// registry of the library, see nbtreader.RegisterFormat.
 const (
-	fileTypeJSON  = "json"
-	fileTypeNBT   = "nbt"
-	fileTypeNJSON = "njson"
-	fileTypeSNBT  = "snbt"
+	fileTypeAuto         = "auto"
+	fileTypeNBT          = "nbt"
+	fileTypeBedrock      = "bedrock"
+	fileTypeBedrockLevel = "bedrock-level"
+	fileTypeSNBT         = "snbt"
 )
 
+// commands holds all subcommands by their name. Each subcommand registers itself in an init
+// function of its own file.
+var commands = map[string]func(args []string){}
+
 var (
-	inputType    *string
-	output       *string
-	outputType   *string
-	uncompressed *bool
+	inputType   *string
+	output      *string
+	outputType  *string
+	compression *string
+	level       *int
+	strict      *bool
+	multi       *bool
+	lossless    *bool
+	strictUTF   *bool
+	verbose     *bool
+
+	limits nbtreader.Limits
+
+	// inputEncoding is the type of binary NBT, that the input is read as: nbt, bedrock or
+	// bedrock-level. Trees from other formats are composed as nbt.
+	inputEncoding = fileTypeNBT
 )
 
 func init() {
-	inputType = flag.String("inType", fileTypeNBT, "The filetype of input file.")
+	formats := strings.Join(nbtreader.Formats(), ", ")
+	inputType = flag.String("inType", fileTypeAuto, "The filetype of input file: "+formats+". auto detects it from the data.")
 	output = flag.String("out", "", "The file to write the output to. If ommitted, output is written to stdout.")
-	outputType = flag.String("outType", fileTypeSNBT, "The filetype of output file.")
-	uncompressed = flag.Bool("uncompressed", false, "If the output NBT data should be raw. Otherwise using GZip compression.")
+	outputType = flag.String("outType", fileTypeSNBT, "The filetype of output file: "+formats+".")
+	compression = flag.String("compression", compressionSource, "The compression of the output NBT data: none, gzip, zlib, lz4 or source to keep the compression of the input. Uncompressed input is written with gzip, except Bedrock files and with -lossless. Conversions to Bedrock are uncompressed with source.")
+	level = flag.Int("level", -1, "The level of gzip and zlib compression from 1 (fastest) to 9 (smallest). -1 uses the default level.")
+	strict = flag.Bool("strict", false, "If data after the root tag should be an error. Otherwise it is ignored.")
+	lossless = flag.Bool("lossless", false, "If the NBT output should reproduce the input byte-exact, like duplicate keys and the compression.")
+	strictUTF = flag.Bool("strictStrings", false, "If strings, that are not valid Modified UTF-8, should be an error. Otherwise their bytes are kept as they are.")
+	verbose = flag.Bool("v", false, "Print the detected format of the input to stderr.")
+	multi = flag.Bool("multi", false, "If the input is a sequence of root tags, that are written one after another.")
+	flag.Int64Var(&limits.MaxBytes, "maxBytes", 0, "The maximum estimated memory size of the parsed data in bytes. 0 means no limit.")
+	flag.IntVar(&limits.MaxDepth, "maxDepth", nbtreader.MaxDepth, "The maximum nesting depth of compounds and lists.")
+	flag.IntVar(&limits.MaxLength, "maxLength", 0, "The maximum number of elements of a list or array. 0 means no limit.")
+	flag.Int64Var(&limits.MaxDecompressedSize, "maxDecompressed", 0, "The maximum size of the decompressed data in bytes. 0 means no limit.")
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [file or archive!file]\n       %s <command> [flags] [args]\n\nCommands:\n", os.Args[0], os.Args[0])
+		names := make([]string, 0, len(commands))
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(flag.CommandLine.Output(), "  %s\n", name)
+		}
+		fmt.Fprintf(flag.CommandLine.Output(), "\nFlags:\n")
+		flag.PrintDefaults()
+	}
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			cmd(os.Args[2:])
+			return
+		}
+	}
+
 	flag.Parse()
 
 	*inputType = strings.ToLower(*inputType)
 	*outputType = strings.ToLower(*outputType)
 
-	if *inputType != fileTypeNBT {
+	if !slices.Contains(nbtreader.Formats(), *inputType) && *inputType != fileTypeAuto {
 		exitUsage(fmt.Errorf("unknown or unsupported input type '%s'", *inputType))
 	}
 
 	var (
-		inFile  *os.File
+		inFile  io.ReadCloser
 		outFile *os.File
 		err     error
 	)
@@ -64,50 +120,60 @@ func main() {
 	} else if flag.Arg(0) == *output {
 		exitUsage(fmt.Errorf("flag '-out': Writing the output to the same file as reading from is not supportet.\nConsider using a temporarily file and rename is afterwards."))
 	} else {
-		inFile, err = os.Open(flag.Arg(0))
+		inFile, err = openFile(flag.Arg(0))
 		if err != nil {
 			exitUsage(err)
 		}
 		defer inFile.Close()
 	}
 
-	nbt, err := nbtreader.New(inFile, outFile)
-	if err != nil {
-		fmt.Println("Error while reading file:")
-		exitUsage(err)
+	opts := []nbtreader.Option{nbtreader.WithLimits(limits), nbtreader.CompressionLevel(*level)}
+	if *strict {
+		opts = append(opts, nbtreader.Strict())
+	}
+	if *lossless {
+		opts = append(opts, nbtreader.Lossless())
+	}
+	if *strictUTF {
+		opts = append(opts, nbtreader.StrictStrings())
 	}
 
-	var out []byte
-	switch *outputType {
-	case fileTypeJSON:
-		out, err = json.MarshalIndent(nbt, "", "	")
-		out = append(out, '\n')
-	case fileTypeNBT:
-		err = nbt.NBT(!*uncompressed)
+	// binary NBT is read as a stream, other formats are read completely to detect and parse them
+	var in io.Reader = inFile
+	if *inputType != fileTypeNBT {
+		data, err := io.ReadAll(inFile)
 		if err != nil {
+			fmt.Println("Error while reading file:")
 			exitUsage(err)
 		}
-		return
-	case fileTypeNJSON:
-		out, err = nbtreader.MarshalNJSON(nbt)
-		out = append(out, '\n')
-	case fileTypeSNBT:
-		out = []byte(fmt.Sprint(nbt))
-		out = append(out, '\n')
-	default:
-		exitUsage(fmt.Errorf("unknown or unsupported output type '%s'", *outputType))
+		binaryOpts, ok := readInput(data, opts, outFile)
+		if !ok {
+			return
+		}
+		opts = append(opts, binaryOpts...)
+		in = bytes.NewReader(data)
 	}
 
-	if err != nil {
-		fmt.Printf("Error while marshalling to output '%s':\n", *outputType)
-		exitUsage(err)
+	if *multi {
+		r := nbtreader.NewReader(in, outFile, opts...)
+		for {
+			nbt, err := r.Next()
+			if err == io.EOF {
+				return
+			} else if err != nil {
+				fmt.Println("Error while reading file:")
+				exitUsage(err)
+			}
+			writeNBT(nbt, outFile)
+		}
 	}
 
-	_, err = outFile.Write(out)
+	nbt, err := nbtreader.New(in, outFile, opts...)
 	if err != nil {
-		fmt.Println("Error while writing output file:")
+		fmt.Println("Error while reading file:")
 		exitUsage(err)
 	}
+	writeNBT(nbt, outFile)
 
 	// TODO: update Compose to io.Writer interface
 	/* Outdated code
@@ -125,6 +191,257 @@ func main() {
 	*/
 }
 
+// readInput writes the input data in the format given by the flag '-inType', or the detected one,
+// to outFile. Binary NBT is left to the caller, which is reported by returning true with the
+// options to read it.
+func readInput(data []byte, opts []nbtreader.Option, outFile io.Writer) ([]nbtreader.Option, bool) {
+	name := *inputType
+	if name == fileTypeAuto {
+		name = nbtreader.DetectFormatName(data)
+	}
+	if name != "" && name != fileTypeNBT {
+		if *inputType == fileTypeAuto && *verbose {
+			fmt.Fprintf(os.Stderr, "detected %s\n", name)
+		}
+		if name == fileTypeBedrock {
+			// a level.dat is read with its header, even if it is not named as input type
+			if format, _ := nbtreader.DetectFormat(data); format == nbtreader.FormatBedrockLevel {
+				name = fileTypeBedrockLevel
+			}
+		}
+		if binaryOpts, ok := encodingOptions(name); ok {
+			inputEncoding = name
+			return binaryOpts, true
+		}
+		tag, err := nbtreader.DecodeAs(name, data)
+		if err != nil {
+			fmt.Println("Error while reading file:")
+			exitUsage(err)
+		}
+		nbt, err := nbtreader.FromRoot(tag, outFile, opts...)
+		if err != nil {
+			exitUsage(err)
+		}
+		writeNBT(nbt, outFile)
+		return nil, false
+	}
+
+	format := nbtreader.FormatNBT
+	if *inputType == fileTypeAuto {
+		var c nbtreader.Compression
+		format, c = nbtreader.DetectFormat(data)
+		if *verbose {
+			switch {
+			case c == nbtreader.ZIP || c == nbtreader.TAR:
+				fmt.Fprintf(os.Stderr, "detected %s archive\n", strings.ToUpper(c.String()))
+			case format == nbtreader.FormatArchive:
+				fmt.Fprintf(os.Stderr, "detected %s compressed TAR archive\n", c)
+			case c != nbtreader.NONE:
+				fmt.Fprintf(os.Stderr, "detected %s compressed %s\n", c, format)
+			default:
+				fmt.Fprintf(os.Stderr, "detected %s\n", format)
+			}
+		}
+	}
+
+	switch format {
+	case nbtreader.FormatNBT:
+		return nil, true
+	case nbtreader.FormatRegion:
+		region, err := nbtreader.OpenRegion(bytes.NewReader(data), opts...)
+		if err != nil {
+			fmt.Println("Error while reading file:")
+			exitUsage(err)
+		}
+		for z := 0; z < 32; z++ {
+			for x := 0; x < 32; x++ {
+				chunk, err := region.Chunk(x, z)
+				if err != nil {
+					fmt.Println("Error while reading file:")
+					exitUsage(err)
+				}
+				if chunk != nil {
+					writeNBT(chunk, outFile)
+				}
+			}
+		}
+	case nbtreader.FormatArchive:
+		exitUsage(fmt.Errorf("input is an archive: read a file in it with <archive>!<file>, see the command list"))
+	default:
+		exitUsage(fmt.Errorf("unsupported input format: %s", format))
+	}
+	return nil, false
+}
+
+// writeNBT writes nbt to outFile in the output type given by the flags.
+func writeNBT(nbt *nbtreader.NBT, outFile io.Writer) {
+	if binaryOpts, ok := encodingOptions(*outputType); ok {
+		c, err := outputCompression(*compression, nbt)
+		if err != nil {
+			exitUsage(fmt.Errorf("flag '-compression': %v", err))
+		}
+		if *compression == compressionSource {
+			switch {
+			case *outputType != fileTypeNBT && *outputType != inputEncoding:
+				// Bedrock does not compress its NBT files
+				c = nbtreader.NONE
+			case *outputType == inputEncoding && (*outputType != fileTypeNBT || *lossless):
+				// uncompressed Bedrock files and lossless copies stay uncompressed
+				c = nbt.Compression()
+			}
+		}
+		if *outputType != inputEncoding {
+			// convert between Java and Bedrock
+			nbt = nbt.Reencode(outFile, binaryOpts...)
+		}
+		if err = nbt.Compose(c); err != nil {
+			exitUsage(err)
+		}
+		return
+	}
+
+	out, err := nbtreader.EncodeAs(*outputType, nbt.Root())
+	if errors.Is(err, nbtreader.ErrFormat) {
+		exitUsage(fmt.Errorf("unknown or unsupported output type '%s'", *outputType))
+	}
+	if err != nil {
+		fmt.Printf("Error while marshalling to output '%s':\n", *outputType)
+		exitUsage(err)
+	}
+
+	_, err = outFile.Write(out)
+	if err != nil {
+		fmt.Println("Error while writing output file:")
+		exitUsage(err)
+	}
+}
+
+// encodingOptions returns the options to read and write binary NBT of the file type, if it is nbt,
+// bedrock or bedrock-level.
+func encodingOptions(fileType string) ([]nbtreader.Option, bool) {
+	switch fileType {
+	case fileTypeNBT:
+		return nil, true
+	case fileTypeBedrock:
+		return []nbtreader.Option{nbtreader.ByteOrder(binary.LittleEndian)}, true
+	case fileTypeBedrockLevel:
+		return []nbtreader.Option{nbtreader.BedrockLevel()}, true
+	}
+	return nil, false
+}
+
+// compressionSource is the value of the flag '-compression', that keeps the compression of the
+// input.
+const compressionSource = "source"
+
+// outputCompression returns the compression for writing nbt by the value of a '-compression'
+// flag. Like [nbtreader.NBT.NBT], source writes uncompressed input compressed with gzip.
+func outputCompression(name string, nbt *nbtreader.NBT) (nbtreader.Compression, error) {
+	if name == compressionSource {
+		if c := nbt.Compression(); c == nbtreader.GZIP || c == nbtreader.ZLIB || c == nbtreader.LZ4 {
+			return c, nil
+		}
+		return nbtreader.GZIP, nil
+	}
+	var c nbtreader.Compression
+	err := c.UnmarshalText([]byte(name))
+	return c, err
+}
+
+// readNBT opens and parses the NBT file with the given name. An empty name or "-" reads from stdin.
+// Files in the other registered formats, like SNBT, JSON and NJSON, are detected and parsed as
+// well.
+// An empty file results in a nil root tag, so it can be used as a missing side of a diff.
+func readNBT(filename string) (nbtreader.NbtTag, error) {
+	var in io.Reader
+	if filename == "" || filename == "-" {
+		in = os.Stdin
+	} else {
+		f, err := openFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	name := nbtreader.DetectFormatName(data)
+	if name == "" {
+		// unknown data is read as NBT for a meaningful error
+		name = fileTypeNBT
+	}
+	tag, err := nbtreader.DecodeAs(name, data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return tag, nil
+}
+
+// createOutput returns a writer for the output file with the given name. An empty name writes to
+// stdout. The file is created on the first write, so nothing is left behind if the command fails
+// before producing any output. The output file must not be the same as the input file.
+func createOutput(filename, input string) (io.WriteCloser, error) {
+	if filename == "" {
+		return os.Stdout, nil
+	}
+	if filename == input {
+		return nil, fmt.Errorf("flag '-out': Writing the output to the same file as reading from is not supportet.\nConsider using a temporarily file and rename is afterwards.")
+	}
+	return &lazyFile{name: filename}, nil
+}
+
+// lazyFile is an io.WriteCloser, that creates the file with the given name on the first write.
+type lazyFile struct {
+	name string
+	f    *os.File
+}
+
+func (l *lazyFile) Write(p []byte) (int, error) {
+	if l.f == nil {
+		f, err := os.Create(l.name)
+		if err != nil {
+			return 0, err
+		}
+		l.f = f
+	}
+	return l.f.Write(p)
+}
+
+func (l *lazyFile) Close() error {
+	if l.f == nil {
+		return nil
+	}
+	return l.f.Close()
+}
+
+// writeOutput writes data to the file with the given name. An empty name writes to stdout.
+func writeOutput(filename string, data []byte) error {
+	if filename == "" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
+// exitCommand prints the error, if any, and the usage of the subcommand fs and then calls
+// os.Exit(2) to exit the program.
+func exitCommand(fs *flag.FlagSet, err error) {
+	if err != nil {
+		fmt.Fprintln(fs.Output(), err)
+	}
+	fs.Usage()
+
+	os.Exit(2)
+}
+
 // exitUsage prints the error, if any, and the command usage and then
 // calls os.Exit(1) to exit the program
 func exitUsage(err error) {
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Kesuaheli/nbtreader"
+)
+
+func init() {
+	commands["merge-driver"] = mergeDriverCmd
+}
+
+func mergeDriverCmd(args []string) {
+	fs := flag.NewFlagSet("merge-driver", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s merge-driver <base file> <our file> <their file> [path]\n\nMerges the changes of both sides into our file. Conflicting changes keep our value. Exits with status 1 if there were conflicts.\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 3 && fs.NArg() != 4 {
+		exitCommand(fs, fmt.Errorf("merge-driver takes three files and an optional path, got %d arguments", fs.NArg()))
+	}
+	baseFile, ourFile, theirFile := fs.Arg(0), fs.Arg(1), fs.Arg(2)
+	name := ourFile
+	if fs.NArg() == 4 {
+		name = fs.Arg(3)
+	}
+
+	base, err := readNBT(baseFile)
+	if err != nil {
+		exitCommand(fs, err)
+	}
+	theirs, err := readNBT(theirFile)
+	if err != nil {
+		exitCommand(fs, err)
+	}
+
+	// our file is overwritten with the result, so it is read completely beforehand
+	ourData, err := os.ReadFile(ourFile)
+	if err != nil {
+		exitCommand(fs, err)
+	}
+	out := &lazyFile{name: ourFile}
+	defer out.Close()
+	nbt, err := nbtreader.New(bytes.NewReader(ourData), out)
+	if err != nil {
+		exitCommand(fs, fmt.Errorf("%s: %v", ourFile, err))
+	}
+
+	merged, conflicts := nbtreader.Merge(base, nbt.Root(), theirs)
+	if err = nbt.SetRoot(merged); err != nil {
+		exitCommand(fs, err)
+	}
+	if err = nbt.NBT(nbt.Compressed()); err != nil {
+		exitCommand(fs, err)
+	}
+
+	for _, c := range conflicts {
+		fmt.Fprintf(os.Stderr, "CONFLICT (content): Merge conflict in %s at %s\n", name, c.Path.Display())
+		for _, side := range []struct {
+			name string
+			tag  nbtreader.NbtTag
+		}{{"base", c.Base}, {"ours", c.Ours}, {"theirs", c.Theirs}} {
+			value := "(missing)"
+			if side.tag != nil {
+				value = snbt(side.tag)
+			}
+			fmt.Fprintf(os.Stderr, "  %-7s %s\n", side.name+":", truncate(value, 120))
+		}
+	}
+	if len(conflicts) > 0 {
+		// git treats a non-zero exit status as an unresolved merge
+		out.Close()
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Kesuaheli/nbtreader"
+)
+
+func init() {
+	commands["patch"] = patchCmd
+	commands["apply"] = applyCmd
+}
+
+func patchCmd(args []string) {
+	fs := flag.NewFlagSet("patch", flag.ExitOnError)
+	output := fs.String("out", "", "The file to write the patch to. If ommitted, the patch is written to stdout.")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s patch [flags] <old file> <new file>\n\nCreates a patch that turns the old file into the new file.\n\nFlags:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		exitCommand(fs, fmt.Errorf("patch takes exactly two files, got %d", fs.NArg()))
+	}
+	a, err := readNBT(fs.Arg(0))
+	if err != nil {
+		exitCommand(fs, err)
+	}
+	b, err := readNBT(fs.Arg(1))
+	if err != nil {
+		exitCommand(fs, err)
+	}
+
+	out, err := json.MarshalIndent(nbtreader.CreatePatch(a, b), "", "	")
+	if err != nil {
+		exitCommand(fs, err)
+	}
+	if err = writeOutput(*output, append(out, '\n')); err != nil {
+		exitCommand(fs, err)
+	}
+}
+
+func applyCmd(args []string) {
+	fs := flag.NewFlagSet("apply", flag.ExitOnError)
+	output := fs.String("out", "", "The file to write the patched NBT data to. If ommitted, output is written to stdout.")
+	compression := fs.String("compression", compressionSource, "The compression of the output NBT data: none, gzip, zlib, lz4 or source to keep the compression of the input, or gzip if it is uncompressed.")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s apply [flags] <patch file> <file>\n\nApplies a patch created by the patch command to the file.\n\nFlags:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		exitCommand(fs, fmt.Errorf("apply takes exactly a patch and a file, got %d arguments", fs.NArg()))
+	}
+	patchData, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		exitCommand(fs, err)
+	}
+	var patch nbtreader.Patch
+	if err = json.Unmarshal(patchData, &patch); err != nil {
+		exitCommand(fs, fmt.Errorf("%s: %v", fs.Arg(0), err))
+	}
+
+	in, err := openFile(fs.Arg(1))
+	if err != nil {
+		exitCommand(fs, err)
+	}
+	defer in.Close()
+	out, err := createOutput(*output, fs.Arg(1))
+	if err != nil {
+		exitCommand(fs, err)
+	}
+	defer out.Close()
+
+	nbt, err := nbtreader.New(in, out)
+	if err != nil {
+		exitCommand(fs, fmt.Errorf("%s: %v", fs.Arg(1), err))
+	}
+	root, err := nbtreader.ApplyPatch(nbt.Root(), patch)
+	if err != nil {
+		exitCommand(fs, err)
+	}
+	if err = nbt.SetRoot(root); err != nil {
+		exitCommand(fs, err)
+	}
+	c, err := outputCompression(*compression, nbt)
+	if err != nil {
+		exitCommand(fs, fmt.Errorf("flag '-compression': %v", err))
+	}
+	if err = nbt.Compose(c); err != nil {
+		exitCommand(fs, err)
+	}
+}
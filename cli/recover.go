@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Kesuaheli/nbtreader"
+)
+
+func init() {
+	commands["recover"] = recoverCmd
+}
+
+func recoverCmd(args []string) {
+	fs := flag.NewFlagSet("recover", flag.ExitOnError)
+	output := fs.String("out", "", "The file to write the recovered NBT data to. If ommitted, output is written to stdout.")
+	compression := fs.String("compression", compressionSource, "The compression of the output NBT data: none, gzip, zlib, lz4 or source to keep the compression of the input, or gzip if it is uncompressed.")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s recover [flags] <file>\n\nReads a truncated or corrupted file as far as possible and writes everything, that could be recovered. A report of the lost data is printed to stderr.\n\nFlags:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		exitCommand(fs, fmt.Errorf("recover takes exactly one file, got %d arguments", fs.NArg()))
+	}
+
+	in, err := openFile(fs.Arg(0))
+	if err != nil {
+		exitCommand(fs, err)
+	}
+	defer in.Close()
+	out, err := createOutput(*output, fs.Arg(0))
+	if err != nil {
+		exitCommand(fs, err)
+	}
+	defer out.Close()
+
+	nbt, err := nbtreader.New(in, out, nbtreader.Salvage())
+	if err != nil {
+		exitCommand(fs, fmt.Errorf("%s: nothing could be recovered: %v", fs.Arg(0), err))
+	}
+	if report := nbt.Salvaged(); report != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s", fs.Arg(0), report)
+	} else {
+		fmt.Fprintf(os.Stderr, "%s: file is complete, nothing was lost\n", fs.Arg(0))
+	}
+	c, err := outputCompression(*compression, nbt)
+	if err != nil {
+		exitCommand(fs, fmt.Errorf("flag '-compression': %v", err))
+	}
+	if err = nbt.Compose(c); err != nil {
+		exitCommand(fs, err)
+	}
+}
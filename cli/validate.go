@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Kesuaheli/nbtreader"
+)
+
+func init() {
+	commands["validate"] = validateCmd
+}
+
+func validateCmd(args []string) {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	schemaFile := fs.String("schema", "", "The JSON schema file to validate against. (required)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s validate -schema <schema file> <file>...\n\nValidates the files against the schema and prints all violations. Exits with status 1 if any file is invalid.\n\nFlags:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *schemaFile == "" {
+		exitCommand(fs, fmt.Errorf("flag '-schema' is required"))
+	}
+	if fs.NArg() == 0 {
+		exitCommand(fs, fmt.Errorf("validate takes at least one file"))
+	}
+
+	f, err := os.Open(*schemaFile)
+	if err != nil {
+		exitCommand(fs, err)
+	}
+	schema, err := nbtreader.LoadSchema(f)
+	f.Close()
+	if err != nil {
+		exitCommand(fs, fmt.Errorf("%s: %v", *schemaFile, err))
+	}
+
+	invalid := false
+	for _, filename := range fs.Args() {
+		tag, err := readNBT(filename)
+		if err != nil {
+			fmt.Println(err)
+			invalid = true
+			continue
+		}
+		for _, v := range schema.Validate(tag) {
+			fmt.Printf("%s: %s: %s\n", filename, v.Path.Display(), v.Message)
+			invalid = true
+		}
+	}
+	if invalid {
+		os.Exit(1)
+	}
+}
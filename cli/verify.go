@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Kesuaheli/nbtreader"
+)
+
+func init() {
+	commands["verify-roundtrip"] = verifyRoundTripCmd
+}
+
+func verifyRoundTripCmd(args []string) {
+	fs := flag.NewFlagSet("verify-roundtrip", flag.ExitOnError)
+	inType := fs.String("inType", fileTypeAuto, "The filetype of the files: nbt, bedrock, bedrock-level or auto to detect it from the data.")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s verify-roundtrip [flags] <file>...\n\nReads each file in lossless mode, writes it again and reports, if the written data differs from the original. Exits with status 1 if any file differs.\n\nFlags:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	*inType = strings.ToLower(*inType)
+	if _, ok := encodingOptions(*inType); !ok && *inType != fileTypeAuto {
+		exitCommand(fs, fmt.Errorf("unknown or unsupported input type '%s'", *inType))
+	}
+
+	if fs.NArg() == 0 {
+		exitCommand(fs, fmt.Errorf("verify-roundtrip takes at least one file"))
+	}
+
+	failed := false
+	for _, filename := range fs.Args() {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			fmt.Println(err)
+			failed = true
+			continue
+		}
+		fileType := *inType
+		if fileType == fileTypeAuto {
+			fileType = detectBinaryType(data)
+		}
+		opts, _ := encodingOptions(fileType)
+		err = nbtreader.VerifyRoundTrip(data, opts...)
+		var div *nbtreader.Divergence
+		switch {
+		case err == nil:
+			fmt.Printf("%s: ok\n", filename)
+		case errors.As(err, &div) && div.Compressed:
+			fmt.Printf("%s: compressed data differs at offset %d\n", filename, div.Offset)
+			failed = true
+		case errors.As(err, &div):
+			fmt.Printf("%s: differs at offset %d in %s\n", filename, div.Offset, div.Path.Display())
+			failed = true
+		default:
+			fmt.Printf("%s: %v\n", filename, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// detectBinaryType returns the file type of the binary NBT data: bedrock or bedrock-level for the
+// data of Bedrock Edition and nbt otherwise.
+func detectBinaryType(data []byte) string {
+	switch format, _ := nbtreader.DetectFormat(data); format {
+	case nbtreader.FormatBedrock:
+		return fileTypeBedrock
+	case nbtreader.FormatBedrockLevel:
+		return fileTypeBedrockLevel
+	}
+	return fileTypeNBT
+}
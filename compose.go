@@ -2,124 +2,216 @@ package nbtreader
 
 import (
 	"encoding/binary"
+	"fmt"
 	"io"
 	"math"
 )
 
-func (t Byte) compose(w io.Writer) error {
-	return pushByte(w, t)
-}
-func (t Short) compose(w io.Writer) error {
-	return pushShort(w, t)
-}
-func (t Int) compose(w io.Writer) error {
-	return pushInt(w, t)
-}
-func (t Long) compose(w io.Writer) error {
-	return pushLong(w, t)
+// encoder writes NBT data and keeps track of the path of the tag, that is currently composed.
+type encoder struct {
+	w    io.Writer
+	path Path
+
+	// strictStrings rejects strings, that are not valid UTF-8
+	strictStrings bool
+
+	// order is the byte order of numbers and lengths, utf8 writes strings as standard UTF-8
+	// instead of Modified UTF-8, both for Bedrock's NBT
+	order binary.ByteOrder
+	utf8  bool
+
+	// lossless holds the details of the parsed data, that are needed to write it byte-exact. It
+	// is nil if not in lossless mode.
+	lossless *lossless
+
+	// buf is reused for writing numbers, strings and arrays
+	buf []byte
 }
-func (t Float) compose(w io.Writer) error {
-	return pushFloat(w, t)
+
+// newEncoder returns an encoder for w, configured by opts.
+func newEncoder(w io.Writer, opts options) *encoder {
+	return &encoder{w: w, strictStrings: opts.strictStrings, order: opts.byteOrder(), utf8: opts.utf8()}
 }
-func (t Double) compose(w io.Writer) error {
-	return pushDouble(w, t)
+
+func (e *encoder) Write(p []byte) (int, error) {
+	return e.w.Write(p)
 }
-func (t ByteArray) compose(w io.Writer) error {
-	if err := pushInt(w, len(t)); err != nil {
-		return err
+
+// buffer returns a buffer of n bytes from w, that is reused if w is an encoder.
+func buffer(w io.Writer, n int) []byte {
+	e, ok := w.(*encoder)
+	if !ok {
+		return make([]byte, n)
+	}
+	if cap(e.buf) < n {
+		e.buf = make([]byte, n)
 	}
-	for _, b := range t {
-		if err := pushByte(w, b); err != nil {
+	return e.buf[:n]
+}
+
+// writeArray writes the length n of an array and its elements of size bytes each, which put puts
+// into b for index i. The data is written in chunks of arrayChunk bytes, with the length in the
+// first one.
+func writeArray(e *encoder, n, size int, put func(b []byte, i int)) error {
+	b := buffer(e, 4+min(n*size, arrayChunk))
+	byteOrder(e).PutUint32(b, uint32(n))
+	off := 4
+	for i := 0; ; off = 0 {
+		k := min(n-i, (len(b)-off)/size)
+		for j := 0; j < k; j++ {
+			put(b[off+j*size:], i+j)
+		}
+		if _, err := e.Write(b[:off+k*size]); err != nil {
 			return err
 		}
+		if i += k; i == n {
+			return nil
+		}
 	}
-	return nil
 }
-func (t String) compose(w io.Writer) error {
-	return pushString(w, t)
+
+func (t Byte) compose(e *encoder) error {
+	return pushByte(e, t)
+}
+func (t Short) compose(e *encoder) error {
+	return pushShort(e, t)
+}
+func (t Int) compose(e *encoder) error {
+	return pushInt(e, t)
+}
+func (t Long) compose(e *encoder) error {
+	return pushLong(e, t)
+}
+func (t Float) compose(e *encoder) error {
+	return pushFloat(e, t)
+}
+func (t Double) compose(e *encoder) error {
+	return pushDouble(e, t)
+}
+func (t ByteArray) compose(e *encoder) error {
+	return writeArray(e, len(t), 1, func(b []byte, i int) {
+		b[0] = byte(t[i])
+	})
 }
-func (t List) compose(w io.Writer) error {
+func (t String) compose(e *encoder) error {
+	return pushString(e, t)
+}
+func (t List) compose(e *encoder) error {
 	itemCap := len(t.Elements)
 	if itemCap == 0 {
-		if err := pushByte(w, Tag_End); err != nil {
+		tagType := Tag_End
+		if e.lossless != nil {
+			// keep the element type of the parsed list
+			tagType = t.TagType
+		}
+		if err := pushByte(e, tagType); err != nil {
 			return err
 		}
-		return pushInt(w, 0)
+		return pushInt(e, 0)
 	}
 
-	if err := pushByte(w, t.TagType); err != nil {
+	if err := pushByte(e, t.TagType); err != nil {
 		return err
 	}
-	if err := pushInt(w, itemCap); err != nil {
+	if err := pushInt(e, itemCap); err != nil {
 		return err
 	}
-	for _, entry := range t.Elements {
-		if err := entry.compose(w); err != nil {
+	for i, entry := range t.Elements {
+		if entry == nil {
+			return fmt.Errorf("list element %d is nil", i)
+		}
+		if entry.Type() != t.TagType {
+			return fmt.Errorf("list element %d of type %s in list of %s", i, entry.Type(), t.TagType)
+		}
+		e.path = append(e.path, PathElement{Index: i, IsIndex: true})
+		err := entry.compose(e)
+		e.path = e.path[:len(e.path)-1]
+		if err != nil {
 			return err
 		}
 	}
 	return nil
 }
-func (t Compound) compose(w io.Writer) error {
-	for _, tag := range t.getOrdered() {
-		if err := pushByte(w, tag.Value.Type()); err != nil {
-			return err
+func (t Compound) compose(e *encoder) error {
+	// the tree may not be validated
+	if err := t.checkIndices(); err != nil {
+		return err
+	}
+	var duplicates []duplicate
+	if e.lossless != nil {
+		duplicates = e.lossless.duplicates[compoundID(t)]
+	}
+
+	ordered := t.getOrdered()
+	for pos := 0; len(ordered) > 0 || len(duplicates) > 0; pos++ {
+		var tag orderedCompound
+		if len(duplicates) > 0 && (duplicates[0].pos == pos || len(ordered) == 0) {
+			tag = orderedCompound{Key: duplicates[0].key, Value: duplicates[0].value}
+			duplicates = duplicates[1:]
+			if _, ok := t[tag.Key]; !ok {
+				// the key was removed since parsing, so its overwritten entries are gone as well
+				continue
+			}
+		} else {
+			tag, ordered = ordered[0], ordered[1:]
 		}
-		if err := pushString(w, tag.Key); err != nil {
-			return nil
+
+		if tag.Value == nil {
+			return fmt.Errorf("compound entry %s is nil", quoteString(string(tag.Key)))
 		}
-		if err := tag.Value.compose(w); err != nil {
+		if err := pushByte(e, tag.Value.Type()); err != nil {
 			return err
 		}
-	}
-	return pushByte(w, Tag_End)
-}
-func (t IntArray) compose(w io.Writer) error {
-	if err := pushInt(w, len(t)); err != nil {
-		return err
-	}
-	for _, i := range t {
-		if err := pushInt(w, i); err != nil {
+		if err := pushString(e, tag.Key); err != nil {
 			return err
 		}
-	}
-	return nil
-}
-func (t LongArray) compose(w io.Writer) error {
-	if err := pushInt(w, len(t)); err != nil {
-		return err
-	}
-	for _, l := range t {
-		if err := pushLong(w, l); err != nil {
+		e.path = append(e.path, PathElement{Key: tag.Key})
+		err := tag.Value.compose(e)
+		e.path = e.path[:len(e.path)-1]
+		if err != nil {
 			return err
 		}
 	}
-	return nil
+	return pushByte(e, Tag_End)
+}
+func (t IntArray) compose(e *encoder) error {
+	order := byteOrder(e)
+	return writeArray(e, len(t), 4, func(b []byte, i int) {
+		order.PutUint32(b, uint32(t[i]))
+	})
+}
+func (t LongArray) compose(e *encoder) error {
+	order := byteOrder(e)
+	return writeArray(e, len(t), 8, func(b []byte, i int) {
+		order.PutUint64(b, uint64(t[i]))
+	})
 }
 
 func pushByte[B Byte | int8 | TagType](w io.Writer, b B) error {
-	_, err := w.Write([]byte{byte(b)})
+	buf := buffer(w, 1)
+	buf[0] = byte(b)
+	_, err := w.Write(buf)
 	return err
 }
 
-func pushShort[S Short | int16 | uint8](w io.Writer, s S) error {
-	var buf [2]byte
-	binary.BigEndian.PutUint16(buf[:], uint16(s))
-	_, err := w.Write(buf[:])
+func pushShort[S Short | int16 | uint8 | uint16](w io.Writer, s S) error {
+	buf := buffer(w, 2)
+	byteOrder(w).PutUint16(buf, uint16(s))
+	_, err := w.Write(buf)
 	return err
 }
 
 func pushInt[I Int | int32 | uint16 | int](w io.Writer, i I) error {
-	var buf [4]byte
-	binary.BigEndian.PutUint32(buf[:], uint32(i))
-	_, err := w.Write(buf[:])
+	buf := buffer(w, 4)
+	byteOrder(w).PutUint32(buf, uint32(i))
+	_, err := w.Write(buf)
 	return err
 }
 
 func pushLong[L Long | int64 | uint32 | int](w io.Writer, l L) error {
-	var buf [8]byte
-	binary.BigEndian.PutUint64(buf[:], uint64(l))
-	_, err := w.Write(buf[:])
+	buf := buffer(w, 8)
+	byteOrder(w).PutUint64(buf, uint64(l))
+	_, err := w.Write(buf)
 	return err
 }
 
@@ -131,10 +223,17 @@ func pushDouble[D Double | float64](w io.Writer, d D) error {
 	return pushLong(w, Long(math.Float64bits(float64(d))))
 }
 
-func pushString(w io.Writer, s String) error {
-	if err := pushShort(w, s.Len()); err != nil {
+func pushString(e *encoder, s String) error {
+	// the length is put in front of the string, once it is known
+	b, err := e.appendString(append(e.buf[:0], 0, 0), s)
+	if err != nil {
 		return err
 	}
-	_, err := w.Write([]byte(s))
+	e.buf = b
+	if n := len(b) - 2; n > MaxStringLength {
+		return fmt.Errorf("string length %d exceeds maximum of %d bytes", n, MaxStringLength)
+	}
+	byteOrder(e).PutUint16(b, uint16(len(b)-2))
+	_, err = e.Write(b)
 	return err
 }
@@ -0,0 +1,174 @@
+package nbtreader
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Compression is the compression of NBT data.
+type Compression byte
+
+const (
+	NONE Compression = iota
+	GZIP
+	ZIP
+	TAR
+	ZLIB
+	LZ4
+)
+
+// compressionNames are the names of the compressions used in text formats like CLI flags.
+var compressionNames = map[Compression]string{
+	NONE: "none",
+	GZIP: "gzip",
+	ZIP:  "zip",
+	TAR:  "tar",
+	ZLIB: "zlib",
+	LZ4:  "lz4",
+}
+
+// String implements the fmt.Stringer interface.
+func (c Compression) String() string {
+	if name, ok := compressionNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown compression %d", byte(c))
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (c Compression) MarshalText() ([]byte, error) {
+	name, ok := compressionNames[c]
+	if !ok {
+		return nil, fmt.Errorf("unknown compression %d", byte(c))
+	}
+	return []byte(name), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (c *Compression) UnmarshalText(text []byte) error {
+	for compression, name := range compressionNames {
+		if strings.EqualFold(name, string(text)) {
+			*c = compression
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown compression '%s'", text)
+}
+
+// CompressionLevel sets the level used to write gzip and zlib compressed data, from
+// gzip.BestSpeed (1) to gzip.BestCompression (9). By default, gzip.DefaultCompression is used.
+func CompressionLevel(level int) Option {
+	return func(o *options) {
+		o.level = level
+		o.hasLevel = true
+	}
+}
+
+// Compression returns the compression of the parsed data.
+func (nbt NBT) Compression() Compression {
+	return nbt.compression
+}
+
+// detectCompression returns the compression of data, that starts with buf.
+func detectCompression(buf []byte) Compression {
+	switch {
+	case bytes.HasPrefix(buf, []byte{0x1f, 0x8b, 0x08}):
+		return GZIP
+	case bytes.HasPrefix(buf, []byte{0x50, 0x4b, 0x03, 0x04}):
+		return ZIP
+	case isTar(buf):
+		return TAR
+	case bytes.HasPrefix(buf, lz4Magic[:4]):
+		return LZ4
+	case len(buf) >= 2 && buf[0] == 0x78 && (uint16(buf[0])<<8|uint16(buf[1]))%31 == 0:
+		// the zlib header with a window size of 32K and a valid checksum
+		return ZLIB
+	default:
+		return NONE
+	}
+}
+
+func (nbt *NBT) decompress() error {
+	// shorter data can't have any of the magic numbers and fails later while parsing
+	buf, _ := nbt.rw.Peek(tarMagicOffset + 5)
+	c := detectCompression(buf)
+	nbt.compression = c
+
+	var r io.Reader
+	switch c {
+	case NONE:
+		return nil
+	case GZIP:
+		gzipReader, err := gzip.NewReader(nbt.rw.Reader)
+		if err != nil {
+			return err
+		}
+		if nbt.lossless != nil {
+			header := gzipReader.Header
+			nbt.lossless.gzipHeader = &header
+		}
+		r = gzipReader
+	case ZLIB:
+		zlibReader, err := zlib.NewReader(nbt.rw.Reader)
+		if err != nil {
+			return err
+		}
+		r = zlibReader
+	case LZ4:
+		lz4Reader := newLZ4Reader(nbt.rw.Reader)
+		lz4Reader.max = nbt.opts.limits.MaxDecompressedSize
+		r = lz4Reader
+	case ZIP, TAR:
+		return fmt.Errorf("file is a %s archive: use OpenArchive to read the files in it", strings.ToUpper(c.String()))
+	default:
+		return fmt.Errorf("file has unsupported compression: %2x", c)
+	}
+	nbt.rw.Reader = bufio.NewReader(r)
+	if buf, _ := nbt.rw.Peek(tarMagicOffset + 5); isTar(buf) {
+		return fmt.Errorf("file is a %s compressed TAR archive: use OpenArchive to read the files in it", strings.ToUpper(c.String()))
+	}
+	return nil
+}
+
+// compressor returns a writer, that compresses data with c to w. It must be closed to complete
+// the compressed data, which doesn't close w.
+func (nbt *NBT) compressor(w io.Writer, c Compression) (io.WriteCloser, error) {
+	level := gzip.DefaultCompression
+	if nbt.opts.hasLevel {
+		level = nbt.opts.level
+	}
+
+	switch c {
+	case NONE:
+		return nopCloser{w}, nil
+	case GZIP:
+		gzipWriter, err := gzip.NewWriterLevel(w, level)
+		if err != nil {
+			return nil, err
+		}
+		if nbt.lossless != nil && nbt.lossless.gzipHeader != nil {
+			gzipWriter.Header = *nbt.lossless.gzipHeader
+		}
+		return gzipWriter, nil
+	case ZLIB:
+		return zlib.NewWriterLevel(w, level)
+	case LZ4:
+		return newLZ4Writer(w), nil
+	default:
+		return nil, fmt.Errorf("writing %s compressed data is not supported", c)
+	}
+}
+
+// nopCloser is an io.WriteCloser, that does nothing on Close.
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,232 @@
+package nbtreader
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// Format is the format of some data, as detected by [DetectFormat].
+type Format byte
+
+const (
+	FormatUnknown Format = iota
+	// FormatNBT is binary NBT of Java Edition in big-endian byte order.
+	FormatNBT
+	// FormatBedrock is binary NBT of Bedrock Edition in little-endian byte order.
+	FormatBedrock
+	// FormatBedrockLevel is Bedrock NBT with the header of level.dat files, holding the storage
+	// version and the length of the data.
+	FormatBedrockLevel
+	FormatRegion
+	FormatSNBT
+	FormatJSON
+	FormatNJSON
+	// FormatArchive is a ZIP or TAR archive, see [OpenArchive].
+	FormatArchive
+)
+
+var formatNames = map[Format]string{
+	FormatUnknown:      "unknown",
+	FormatNBT:          "NBT",
+	FormatBedrock:      "Bedrock NBT",
+	FormatBedrockLevel: "Bedrock level.dat",
+	FormatRegion:       "region file",
+	FormatSNBT:         "SNBT",
+	FormatJSON:         "JSON",
+	FormatNJSON:        "NJSON",
+	FormatArchive:      "archive",
+}
+
+// String implements the fmt.Stringer interface.
+func (f Format) String() string {
+	if name, ok := formatNames[f]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown format %d", byte(f))
+}
+
+// detectPrefixSize is the amount of decompressed data, that is checked for binary NBT.
+const detectPrefixSize = 1 << 12
+
+// DetectFormat returns the format of data and, for binary NBT and archives, its compression.
+// Binary NBT is detected by the structure of the root tag and its first entry, region files by
+// their size and the layout of their header, and text by its syntax.
+func DetectFormat(data []byte) (Format, Compression) {
+	if isRegion(data) {
+		return FormatRegion, NONE
+	}
+
+	c := detectCompression(data)
+	switch c {
+	case ZIP, TAR:
+		return FormatArchive, c
+	case NONE:
+	default:
+		prefix := decompressPrefix(data, c)
+		if isTar(prefix) {
+			return FormatArchive, c
+		}
+		return detectBinary(prefix), c
+	}
+
+	if f := detectBinary(data); f != FormatUnknown {
+		return f, NONE
+	}
+	return detectText(data), NONE
+}
+
+// decompressPrefix returns the start of the data decompressed with c.
+func decompressPrefix(data []byte, c Compression) []byte {
+	var (
+		r   io.Reader = bytes.NewReader(data)
+		err error
+	)
+	switch c {
+	case GZIP:
+		r, err = gzip.NewReader(r)
+	case ZLIB:
+		r, err = zlib.NewReader(r)
+	case LZ4:
+		r = newLZ4Reader(r)
+	}
+	if err != nil {
+		return nil
+	}
+	prefix := make([]byte, detectPrefixSize)
+	n, _ := io.ReadFull(r, prefix)
+	return prefix[:n]
+}
+
+// detectBinary returns the format of uncompressed binary NBT, that starts with b, or
+// FormatUnknown. If both byte orders are plausible, Java's big-endian is preferred. The header of
+// Bedrock's level.dat is checked first, as its storage version looks like a compound root tag.
+func detectBinary(b []byte) Format {
+	if len(b) >= 8 && int(binary.LittleEndian.Uint32(b[4:])) == len(b)-8 && plausibleNBT(b[8:], binary.LittleEndian) {
+		return FormatBedrockLevel
+	}
+	if plausibleNBT(b, binary.BigEndian) {
+		return FormatNBT
+	}
+	if plausibleNBT(b, binary.LittleEndian) {
+		return FormatBedrock
+	}
+	return FormatUnknown
+}
+
+// plausibleNBT reports whether b starts like binary NBT in the given byte order: a compound or
+// list root tag with a valid name, followed by the header of its first entry.
+func plausibleNBT(b []byte, order binary.ByteOrder) bool {
+	if len(b) < 1 || TagType(b[0]) != Tag_Compound && TagType(b[0]) != Tag_List {
+		return false
+	}
+	rest, ok := plausibleName(b[1:], order)
+	if !ok || len(rest) == 0 {
+		return false
+	}
+	if TagType(b[0]) == Tag_List {
+		return len(rest) >= 5 && TagType(rest[0]) <= Tag_Long_Array && int32(order.Uint32(rest[1:])) >= 0
+	}
+	switch t := TagType(rest[0]); {
+	case t == Tag_End:
+		// an empty compound
+		return true
+	case t > Tag_Long_Array:
+		return false
+	}
+	_, ok = plausibleName(rest[1:], order)
+	return ok
+}
+
+// plausibleName reports whether b starts with a tag name of valid UTF-8 without control
+// characters, and returns the data after it.
+func plausibleName(b []byte, order binary.ByteOrder) ([]byte, bool) {
+	if len(b) < 2 {
+		return nil, false
+	}
+	n := int(order.Uint16(b))
+	if n > len(b)-2 {
+		return nil, false
+	}
+	name := b[2 : 2+n]
+	if !utf8.Valid(name) {
+		return nil, false
+	}
+	for _, c := range name {
+		if c < ' ' || c == 0x7f {
+			return nil, false
+		}
+	}
+	return b[2+n:], true
+}
+
+// isRegion reports whether data is a region file: a header of 1024 chunk locations and
+// timestamps, where every location points to sectors after the header and within the data, and
+// the first chunk starts with a valid length and compression.
+func isRegion(data []byte) bool {
+	if len(data) < regionSectorSize*2 {
+		return false
+	}
+	sectors := (len(data) + regionSectorSize - 1) / regionSectorSize
+	first := 0
+	for i := 0; i < regionChunks; i++ {
+		location := binary.BigEndian.Uint32(data[i*4:])
+		if location == 0 {
+			continue
+		}
+		offset, count := int(location>>8), int(location&0xff)
+		if offset < 2 || count == 0 || offset+count > sectors {
+			return false
+		}
+		if first == 0 || offset < first {
+			first = offset
+		}
+	}
+	if first == 0 {
+		// an empty region has only the header
+		return len(data) == regionSectorSize*2
+	}
+
+	chunk := data[first*regionSectorSize:]
+	if len(chunk) < 5 {
+		return false
+	}
+	length := int(binary.BigEndian.Uint32(chunk))
+	switch chunk[4] &^ chunkExternal {
+	case chunkGZIP, chunkZLIB, chunkNONE, chunkLZ4:
+	default:
+		return false
+	}
+	return length > 0 && length <= len(chunk)-4
+}
+
+// detectText returns the text format of data, that starts with a compound or list, or
+// FormatUnknown.
+func detectText(data []byte) Format {
+	text := bytes.TrimLeft(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), " \t\r\n")
+	if len(text) == 0 || text[0] != '{' && text[0] != '[' {
+		return FormatUnknown
+	}
+	if !json.Valid(text) {
+		return FormatSNBT
+	}
+
+	// NJSON has type annotations on keys and at the start of lists
+	dec := json.NewDecoder(bytes.NewReader(text))
+	for {
+		token, err := dec.Token()
+		if err != nil {
+			return FormatJSON
+		}
+		if s, ok := token.(string); ok {
+			if _, anno := splitAnnotation(s); anno != NoAnnotation {
+				return FormatNJSON
+			}
+		}
+	}
+}
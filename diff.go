@@ -0,0 +1,264 @@
+package nbtreader
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+)
+
+// ChangeKind describes what kind of difference a [Change] is.
+type ChangeKind uint8
+
+const (
+	// Added means the tag only exists in the new tree.
+	Added ChangeKind = iota
+	// Removed means the tag only exists in the old tree.
+	Removed
+	// Changed means the tag has the same type in both trees, but a different value.
+	Changed
+	// TypeChanged means the tag exists in both trees, but with a different type. For lists this
+	// also means a different element type.
+	TypeChanged
+	// Reordered means a compound has the same keys in both trees, but in a different order.
+	Reordered
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Changed:
+		return "changed"
+	case TypeChanged:
+		return "type-changed"
+	case Reordered:
+		return "reordered"
+	default:
+		return fmt.Sprintf("*Unknown Change %d*", k)
+	}
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (k ChangeKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// Change is a single difference between two trees of tags as returned by [Diff].
+//
+// Old is nil for [Added] changes and New is nil for [Removed] changes. For [Reordered] changes
+// Old and New are lists of strings holding the keys of the compound in their respective order.
+type Change struct {
+	Path Path
+	Kind ChangeKind
+	Old  NbtTag
+	New  NbtTag
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %s", c.Path, c.Kind)
+}
+
+// MarshalJSON implements the json.Marshaler interface. The old and new values are encoded as SNBT
+// strings, so they keep their exact types.
+func (c Change) MarshalJSON() ([]byte, error) {
+	v := struct {
+		Path string     `json:"path"`
+		Kind ChangeKind `json:"kind"`
+		Old  string     `json:"old,omitempty"`
+		New  string     `json:"new,omitempty"`
+	}{Path: c.Path.String(), Kind: c.Kind}
+
+	if c.Old != nil {
+		b, err := MarshalSNBT(c.Old)
+		if err != nil {
+			return nil, err
+		}
+		v.Old = string(b)
+	}
+	if c.New != nil {
+		b, err := MarshalSNBT(c.New)
+		if err != nil {
+			return nil, err
+		}
+		v.New = string(b)
+	}
+	return json.Marshal(v)
+}
+
+// Diff compares the trees a and b and returns all differences between them, in the order they
+// appear in the trees. A nil tree is treated as not existing at all.
+//
+// Compounds are compared key by key, lists element by element. Byte, int and long arrays are
+// compared as a whole and reported as a single change.
+func Diff(a, b NbtTag) []Change {
+	var changes []Change
+	diff(&changes, Path{}, a, b)
+	return changes
+}
+
+func diff(changes *[]Change, p Path, a, b NbtTag) {
+	switch {
+	case a == nil && b == nil:
+		return
+	case a == nil:
+		*changes = append(*changes, Change{Path: p, Kind: Added, New: b})
+		return
+	case b == nil:
+		*changes = append(*changes, Change{Path: p, Kind: Removed, Old: a})
+		return
+	case a.Type() != b.Type():
+		*changes = append(*changes, Change{Path: p, Kind: TypeChanged, Old: a, New: b})
+		return
+	}
+
+	switch a := a.(type) {
+	case Compound:
+		diffCompound(changes, p, a, b.(Compound))
+	case List:
+		diffList(changes, p, a, b.(List))
+	default:
+		if !Equal(a, b) {
+			*changes = append(*changes, Change{Path: p, Kind: Changed, Old: a, New: b})
+		}
+	}
+}
+
+func diffCompound(changes *[]Change, p Path, a, b Compound) {
+	aKeys, bKeys := a.Keys(), b.Keys()
+
+	var aCommon, bCommon []String
+	for _, k := range aKeys {
+		if _, ok := b[k]; ok {
+			aCommon = append(aCommon, k)
+		}
+	}
+	for _, k := range bKeys {
+		if _, ok := a[k]; ok {
+			bCommon = append(bCommon, k)
+		}
+	}
+	if !slices.Equal(aCommon, bCommon) {
+		*changes = append(*changes, Change{
+			Path: p,
+			Kind: Reordered,
+			Old:  keyList(aKeys),
+			New:  keyList(bKeys),
+		})
+	}
+
+	for _, k := range aKeys {
+		if bEntry, ok := b[k]; ok {
+			diff(changes, p.Key(k), a[k].Value, bEntry.Value)
+		} else {
+			diff(changes, p.Key(k), a[k].Value, nil)
+		}
+	}
+	for _, k := range bKeys {
+		if _, ok := a[k]; !ok {
+			diff(changes, p.Key(k), nil, b[k].Value)
+		}
+	}
+}
+
+func diffList(changes *[]Change, p Path, a, b List) {
+	if a.TagType != b.TagType && len(a.Elements) > 0 && len(b.Elements) > 0 {
+		*changes = append(*changes, Change{Path: p, Kind: TypeChanged, Old: a, New: b})
+		return
+	}
+
+	for i := 0; i < max(len(a.Elements), len(b.Elements)); i++ {
+		var aElem, bElem NbtTag
+		if i < len(a.Elements) {
+			aElem = a.Elements[i]
+		}
+		if i < len(b.Elements) {
+			bElem = b.Elements[i]
+		}
+		diff(changes, p.Index(i), aElem, bElem)
+	}
+}
+
+func keyList(keys []String) List {
+	l := List{TagType: Tag_String, Elements: make([]NbtTag, len(keys))}
+	for i, k := range keys {
+		l.Elements[i] = k
+	}
+	return l
+}
+
+// Equal reports whether the trees a and b are deeply equal. The order of keys in compounds is not
+// considered. Floating point values are compared by their bits, so NaN values with the same
+// payload are equal.
+func Equal(a, b NbtTag) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if a.Type() != b.Type() {
+		return false
+	}
+	ra, aRaw := a.(RawTag)
+	rb, bRaw := b.(RawTag)
+	if aRaw && bRaw && bytes.Equal(ra.data, rb.data) {
+		return true
+	}
+	if aRaw || bRaw {
+		// undecoded tags are compared by their decoded trees, as the order of keys may differ
+		var err error
+		if aRaw {
+			if a, err = ra.Decode(); err != nil {
+				return false
+			}
+		}
+		if bRaw {
+			if b, err = rb.Decode(); err != nil {
+				return false
+			}
+		}
+		return Equal(a, b)
+	}
+
+	switch a := a.(type) {
+	case Float:
+		return math.Float32bits(float32(a)) == math.Float32bits(float32(b.(Float)))
+	case Double:
+		return math.Float64bits(float64(a)) == math.Float64bits(float64(b.(Double)))
+	case ByteArray:
+		return slices.Equal(a, b.(ByteArray))
+	case IntArray:
+		return slices.Equal(a, b.(IntArray))
+	case LongArray:
+		return slices.Equal(a, b.(LongArray))
+	case List:
+		b := b.(List)
+		if len(a.Elements) != len(b.Elements) {
+			return false
+		}
+		if len(a.Elements) > 0 && a.TagType != b.TagType {
+			return false
+		}
+		for i := range a.Elements {
+			if !Equal(a.Elements[i], b.Elements[i]) {
+				return false
+			}
+		}
+		return true
+	case Compound:
+		b := b.(Compound)
+		if len(a) != len(b) {
+			return false
+		}
+		for k, aEntry := range a {
+			bEntry, ok := b[k]
+			if !ok || !Equal(aEntry.Value, bEntry.Value) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
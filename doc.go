@@ -0,0 +1,99 @@
+package nbtreader
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// Doc holds a tree of tags, that is read and edited by several goroutines at once. Readers get a
+// [Snapshot] of the tree, that never changes. Edits are applied one after another and publish a
+// new snapshot. They replace only the compounds, lists and arrays on the edited paths, so all
+// other tags are shared between the snapshots.
+//
+// The zero value is not usable, create a Doc with [NewDoc].
+type Doc struct {
+	// mu serializes the edits, reads only load current
+	mu      sync.Mutex
+	current atomic.Pointer[Snapshot]
+}
+
+// Snapshot is the state of a [Doc] at one point in time. It is safe for concurrent use, as long as
+// the tree is not modified.
+type Snapshot struct {
+	root    NbtTag
+	version uint64
+}
+
+// NewDoc returns a Doc with a copy of root, so later changes to root do not affect it.
+func NewDoc(root NbtTag) *Doc {
+	doc := &Doc{}
+	doc.current.Store(&Snapshot{root: Clone(root)})
+	return doc
+}
+
+// Snapshot returns the current state of the Doc. It does not wait for edits in progress.
+func (doc *Doc) Snapshot() *Snapshot {
+	return doc.current.Load()
+}
+
+// Apply applies all operations of patch and publishes the result as a new snapshot, which is
+// returned. If any operation fails, the error is returned and the Doc stays unchanged. The values
+// of the operations are copied, so they can be reused by the caller.
+func (doc *Doc) Apply(patch Patch) (*Snapshot, error) {
+	doc.mu.Lock()
+	defer doc.mu.Unlock()
+
+	patch = slices.Clone(patch)
+	for i := range patch {
+		patch[i].Value = Clone(patch[i].Value)
+	}
+	s := doc.current.Load()
+	tree, err := applyPatch(s.root, patch)
+	if err != nil {
+		return nil, err
+	}
+	s = &Snapshot{root: tree, version: s.version + 1}
+	doc.current.Store(s)
+	return s, nil
+}
+
+// Set sets the tag at the path p to a copy of value, like [Doc.Apply] with a single replace
+// operation. New compound keys are added.
+func (doc *Doc) Set(p Path, value NbtTag) (*Snapshot, error) {
+	op := PatchReplace
+	if len(p) > 0 && !p[len(p)-1].IsIndex {
+		// add replaces existing keys as well
+		op = PatchAdd
+	}
+	return doc.Apply(Patch{{Op: op, Path: p, Value: value}})
+}
+
+// Remove removes the tag at the path p, like [Doc.Apply] with a single remove operation.
+func (doc *Doc) Remove(p Path) (*Snapshot, error) {
+	return doc.Apply(Patch{{Op: PatchRemove, Path: p}})
+}
+
+// Root returns the tree of the snapshot. It is shared with other snapshots and must not be
+// modified. Use [Clone] to get a copy to work on, or edit the [Doc] instead.
+func (s *Snapshot) Root() NbtTag {
+	return s.root
+}
+
+// Get returns the tag at the path p. Like the root, it must not be modified.
+func (s *Snapshot) Get(p Path) (NbtTag, error) {
+	return p.Get(s.root)
+}
+
+// Version returns the number of edits, that were applied to the [Doc] before the snapshot.
+func (s *Snapshot) Version() uint64 {
+	return s.version
+}
+
+// String implements the fmt.Stringer interface.
+func (s *Snapshot) String() string {
+	if s.root == nil {
+		return ""
+	}
+	return s.root.String()
+}
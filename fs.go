@@ -0,0 +1,578 @@
+package nbtreader
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The file systems in this file are read-only views of worlds, region files, archives and trees,
+// for use with fs.WalkDir, fs.Glob, http.FS and other code working on an fs.FS:
+//
+//   - [WorldFS] shows the files of a world directory. Region files are directories with a file
+//     c.<x>.<z>.nbt for each chunk, which holds the uncompressed binary NBT of the chunk.
+//   - [Region.FS] shows the chunks of a single region file.
+//   - [Archive.FS] shows the files of a ZIP or TAR archive.
+//   - [TreeFS] shows a tree of tags, where compounds and lists are directories and all other tags
+//     are files with their SNBT.
+//
+// With [BrowseTags], NBT files and chunks in the world and region file systems are shown as
+// directories like in [TreeFS] as well.
+
+const (
+	dirMode  = fs.ModeDir | 0o555
+	fileMode = 0o444
+
+	chunkFileFormat = "c.%d.%d.nbt"
+)
+
+var errIsDir = errors.New("is a directory")
+
+// BrowseTags shows NBT files and chunks as directories in the file systems of [WorldFS] and
+// [Region.FS], like in [TreeFS]. They are parsed with the other options, when opened.
+func BrowseTags() Option {
+	return func(o *options) {
+		o.browse = true
+	}
+}
+
+// WorldFS returns a file system with the files of the world directory fsys, like os.DirFS of the
+// world folder or a sub directory of [Archive.FS]. Region files (.mca and .mcr) are directories
+// with a file for each chunk. The chunks are named by their world chunk coordinates, like
+// region/r.-1.0.mca/c.-32.7.nbt, if the region file has its usual name, and by their coordinates
+// in the region otherwise.
+//
+// The chunks and, with [BrowseTags], the NBT files are parsed with the given options each time
+// they are opened.
+func WorldFS(fsys fs.FS, opts ...Option) fs.FS {
+	w := &worldFS{fsys: fsys, opts: opts}
+	for _, opt := range opts {
+		opt(&w.o)
+	}
+	return w
+}
+
+// FS returns a file system with a file for each chunk of the region, named c.<x>.<z>.nbt by the
+// coordinates of the chunk in the region (0-31). The files hold the uncompressed binary NBT of the
+// chunks. With [BrowseTags] passed to [OpenRegion], the chunks are directories like in [TreeFS].
+func (r *Region) FS() fs.FS {
+	var o options
+	for _, opt := range r.opts {
+		opt(&o)
+	}
+	rfs := &regionFS{r: r, browse: o.browse, info: dirInfo(".", time.Time{}), dir: "."}
+	rfs.root = rfs
+	return rfs
+}
+
+// FS returns a file system with the files of the archive. Directories are derived from the names
+// of the files. Use fs.Sub and [WorldFS] to browse a world in the archive.
+func (a *Archive) FS() fs.FS {
+	afs := &archiveFS{a: a, files: map[string]fileInfo{}, dirs: map[string][]fs.DirEntry{".": nil}}
+	for _, f := range a.files {
+		dir := path.Dir(f.Name)
+		for d := dir; ; d = path.Dir(d) {
+			if _, ok := afs.dirs[d]; ok {
+				break
+			}
+			afs.dirs[d] = nil
+			parent := path.Dir(d)
+			afs.dirs[parent] = append(afs.dirs[parent], dirInfo(path.Base(d), time.Time{}))
+		}
+		info := fileInfo{name: path.Base(f.Name), size: f.Size, mode: fileMode, modTime: f.ModTime}
+		afs.files[f.Name] = info
+		afs.dirs[dir] = append(afs.dirs[dir], info)
+	}
+	return afs
+}
+
+// TreeFS returns a file system with the tree of tag. Compounds and lists are directories, where the
+// elements of lists are named by their index. All other tags are files with their SNBT and a line
+// break. The root is the directory ".", or a file, if tag is neither a compound nor a list.
+//
+// Keys are escaped to valid file names: "%" and "/" are written as "%25" and "%2F", like in URLs,
+// "." and ".." as "%2E" and "%2E%2E", and the empty key as "%".
+func TreeFS(tag NbtTag) fs.FS {
+	return &treeFS{root: tag, name: "."}
+}
+
+// worldFS is the file system of WorldFS.
+type worldFS struct {
+	fsys fs.FS
+	opts []Option
+	o    options
+}
+
+func (w *worldFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	// find the region or NBT file in name, if any
+	elems := strings.Split(name, "/")
+	for i := range elems {
+		file := path.Join(elems[:i+1]...)
+		info, err := fs.Stat(w.fsys, file)
+		if err != nil {
+			return nil, pathError("open", name, err)
+		}
+		if info.IsDir() {
+			continue
+		}
+		rest := path.Join(elems[i+1:]...)
+		if rest == "" {
+			rest = "."
+		}
+
+		var f fs.File
+		switch {
+		case IsRegionFile(file):
+			f, err = w.openRegion(file, info, rest)
+		case w.o.browse && IsNBTFile(file):
+			f, err = w.openTree(file, info, rest)
+		case rest == ".":
+			return w.fsys.Open(name)
+		default:
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+		}
+		if err != nil {
+			return nil, pathError("open", name, err)
+		}
+		return f, nil
+	}
+
+	f, err := w.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if dir, ok := f.(fs.ReadDirFile); ok {
+		return &worldDir{ReadDirFile: dir, w: w, dir: name}, nil
+	}
+	return f, nil
+}
+
+// isDir reports whether the file with the given name is shown as a directory, although it is a
+// file in the world directory.
+func (w *worldFS) isDir(name string) bool {
+	return IsRegionFile(name) || w.o.browse && IsNBTFile(name)
+}
+
+// openRegion opens rest in the region file with the given name.
+func (w *worldFS) openRegion(name string, info fs.FileInfo, rest string) (fs.File, error) {
+	rfs := &regionFS{browse: w.o.browse, info: dirInfo(path.Base(name), info.ModTime()), root: w, dir: name}
+	if x, z, ok := parseRegionName(path.Base(name)); ok {
+		rfs.x, rfs.z = x*32, z*32
+	}
+
+	if info.Size() == 0 {
+		// empty region files are common, but have no header
+		rfs.r = &Region{}
+		return rfs.Open(rest)
+	}
+	f, err := w.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := readerAt(f)
+	if err != nil {
+		return nil, err
+	}
+	if rfs.r, err = OpenRegion(r, w.opts...); err != nil {
+		return nil, err
+	}
+	return rfs.Open(rest)
+}
+
+// openTree opens rest in the tree of the NBT file with the given name.
+func (w *worldFS) openTree(name string, info fs.FileInfo, rest string) (fs.File, error) {
+	f, err := w.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	nbt, err := New(f, nil, w.opts...)
+	if err != nil {
+		return nil, err
+	}
+	t := &treeFS{root: nbt.Root(), name: path.Base(name), modTime: info.ModTime()}
+	return t.Open(rest)
+}
+
+// worldDir is a directory of a world. It shows region and NBT files as directories.
+type worldDir struct {
+	fs.ReadDirFile
+	w   *worldFS
+	dir string
+}
+
+func (d *worldDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	entries, err := d.ReadDirFile.ReadDir(n)
+	for i, e := range entries {
+		if e.Type().IsRegular() && d.w.isDir(e.Name()) {
+			name := path.Join(d.dir, e.Name())
+			entries[i] = fileInfo{name: e.Name(), mode: dirMode, stat: func() (fs.FileInfo, error) {
+				return fs.Stat(d.w, name)
+			}}
+		}
+	}
+	return entries, err
+}
+
+// regionFS is the file system of a region file.
+type regionFS struct {
+	r *Region
+	// x and z are the chunk coordinates of the first chunk of the region
+	x, z   int
+	browse bool
+	info   fileInfo
+	// root is the file system with the region file at dir, to stat the chunks in a directory
+	// listing when needed
+	root fs.FS
+	dir  string
+}
+
+func (rfs *regionFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return &memDir{info: rfs.info, entries: rfs.entries()}, nil
+	}
+
+	chunk, rest, _ := strings.Cut(name, "/")
+	x, z, ok := parseChunkName(chunk)
+	if !ok || x-rfs.x < 0 || x-rfs.x > 31 || z-rfs.z < 0 || z-rfs.z > 31 || !rfs.r.HasChunk(x, z) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	r, err := rfs.r.chunkReader(x, z)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fmt.Errorf("region: chunk %d, %d: %w", x, z, err)}
+	}
+	modTime := rfs.r.Timestamp(x, z)
+
+	if rfs.browse {
+		nbt, err := New(bytes.NewReader(data), nil, rfs.r.opts...)
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fmt.Errorf("region: chunk %d, %d: %w", x, z, err)}
+		}
+		if rest == "" {
+			rest = "."
+		}
+		t := &treeFS{root: nbt.Root(), name: chunk, modTime: modTime}
+		f, err := t.Open(rest)
+		if err != nil {
+			return nil, pathError("open", name, err)
+		}
+		return f, nil
+	}
+	if rest != "" {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return &memFile{Reader: bytes.NewReader(data), info: fileInfo{name: chunk, size: int64(len(data)), mode: fileMode, modTime: modTime}}, nil
+}
+
+// entries returns the chunks of the region. Their sizes are only known after decompressing them,
+// so they are read when the info of an entry is requested.
+func (rfs *regionFS) entries() []fs.DirEntry {
+	var entries []fs.DirEntry
+	for z := 0; z < 32; z++ {
+		for x := 0; x < 32; x++ {
+			if !rfs.r.HasChunk(x, z) {
+				continue
+			}
+			name := fmt.Sprintf(chunkFileFormat, rfs.x+x, rfs.z+z)
+			mode := fs.FileMode(fileMode)
+			if rfs.browse {
+				mode = dirMode
+			}
+			entries = append(entries, fileInfo{name: name, mode: mode, stat: func() (fs.FileInfo, error) {
+				return fs.Stat(rfs.root, path.Join(rfs.dir, name))
+			}})
+		}
+	}
+	return entries
+}
+
+// parseRegionName returns the region coordinates of a region file name like r.-1.0.mca.
+func parseRegionName(name string) (x, z int, ok bool) {
+	ext := path.Ext(name)
+	if _, err := fmt.Sscanf(name, "r.%d.%d.", &x, &z); err != nil {
+		return 0, 0, false
+	}
+	return x, z, fmt.Sprintf("r.%d.%d", x, z)+ext == name
+}
+
+// parseChunkName returns the chunk coordinates of a chunk file name like c.3.7.nbt.
+func parseChunkName(name string) (x, z int, ok bool) {
+	if _, err := fmt.Sscanf(name, chunkFileFormat, &x, &z); err != nil {
+		return 0, 0, false
+	}
+	return x, z, fmt.Sprintf(chunkFileFormat, x, z) == name
+}
+
+// archiveFS is the file system of an archive.
+type archiveFS struct {
+	a     *Archive
+	files map[string]fileInfo
+	dirs  map[string][]fs.DirEntry
+}
+
+func (afs *archiveFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if entries, ok := afs.dirs[name]; ok {
+		return &memDir{info: dirInfo(path.Base(name), time.Time{}), entries: entries}, nil
+	}
+	info, ok := afs.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	rc, err := afs.a.Open(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &archiveFile{ReadCloser: rc, info: info}, nil
+}
+
+// archiveFile is an opened file of an archive.
+type archiveFile struct {
+	io.ReadCloser
+	info fileInfo
+}
+
+func (f *archiveFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+// treeFS is the file system of a tree.
+type treeFS struct {
+	root    NbtTag
+	name    string
+	modTime time.Time
+}
+
+func (t *treeFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	tag, base := t.root, t.name
+	if name != "." {
+		for _, elem := range strings.Split(name, "/") {
+			child, err := tagChild(tag, elem)
+			if err != nil {
+				return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+			}
+			tag, base = child, elem
+		}
+	}
+
+	if raw, ok := tag.(RawTag); ok {
+		decoded, err := raw.Decode()
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		tag = decoded
+	}
+	switch tag := tag.(type) {
+	case Compound:
+		entries := make([]fs.DirEntry, 0, len(tag))
+		for _, comp := range tag.getOrdered() {
+			entries = append(entries, t.entry(fsName(comp.Key), comp.Value))
+		}
+		return &memDir{info: dirInfo(base, t.modTime), entries: entries}, nil
+	case List:
+		entries := make([]fs.DirEntry, len(tag.Elements))
+		for i, element := range tag.Elements {
+			entries[i] = t.entry(strconv.Itoa(i), element)
+		}
+		return &memDir{info: dirInfo(base, t.modTime), entries: entries}, nil
+	default:
+		content := tagContent(tag)
+		return &memFile{Reader: bytes.NewReader(content), info: fileInfo{name: base, size: int64(len(content)), mode: fileMode, modTime: t.modTime}}, nil
+	}
+}
+
+// entry returns the directory entry of tag with the given name.
+func (t *treeFS) entry(name string, tag NbtTag) fs.DirEntry {
+	tagType := tag.Type()
+	if tagType == Tag_Compound || tagType == Tag_List {
+		return dirInfo(name, t.modTime)
+	}
+	return fileInfo{name: name, mode: fileMode, modTime: t.modTime, stat: func() (fs.FileInfo, error) {
+		return fileInfo{name: name, size: int64(len(tagContent(tag))), mode: fileMode, modTime: t.modTime}, nil
+	}}
+}
+
+// tagChild returns the child of tag with the file name elem.
+func tagChild(tag NbtTag, elem string) (NbtTag, error) {
+	if raw, ok := tag.(RawTag); ok {
+		decoded, err := raw.Decode()
+		if err != nil {
+			return nil, err
+		}
+		tag = decoded
+	}
+	switch tag := tag.(type) {
+	case Compound:
+		key, ok := fsKey(elem)
+		if !ok {
+			return nil, fs.ErrNotExist
+		}
+		if child, ok := tag.Get(key); ok {
+			return child, nil
+		}
+	case List:
+		i, err := strconv.Atoi(elem)
+		if err == nil && strconv.Itoa(i) == elem && i >= 0 && i < len(tag.Elements) {
+			return tag.Elements[i], nil
+		}
+	}
+	return nil, fs.ErrNotExist
+}
+
+// tagContent returns the content of the file of tag.
+func tagContent(tag NbtTag) []byte {
+	return []byte(tag.String() + "\n")
+}
+
+var nameEscaper = strings.NewReplacer("%", "%25", "/", "%2F")
+
+// fsName returns the file name of a compound key, see TreeFS.
+func fsName(key String) string {
+	switch key {
+	case "":
+		return "%"
+	case ".":
+		return "%2E"
+	case "..":
+		return "%2E%2E"
+	}
+	return nameEscaper.Replace(string(key))
+}
+
+// fsKey returns the compound key of a file name, see TreeFS.
+func fsKey(name string) (String, bool) {
+	switch name {
+	case "%":
+		return "", true
+	case "%2E":
+		return ".", true
+	case "%2E%2E":
+		return "..", true
+	}
+	key, err := url.PathUnescape(name)
+	if err != nil {
+		return "", false
+	}
+	return String(key), nameEscaper.Replace(key) == name
+}
+
+// fileInfo describes a file in the file systems of this package. It is also a directory entry.
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+	// stat returns the full info of a directory entry, whose size is not known without reading
+	// the file
+	stat func() (fs.FileInfo, error)
+}
+
+func dirInfo(name string, modTime time.Time) fileInfo {
+	return fileInfo{name: name, mode: dirMode, modTime: modTime}
+}
+
+func (i fileInfo) Name() string       { return i.name }
+func (i fileInfo) Size() int64        { return i.size }
+func (i fileInfo) Mode() fs.FileMode  { return i.mode }
+func (i fileInfo) ModTime() time.Time { return i.modTime }
+func (i fileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i fileInfo) Sys() any           { return nil }
+func (i fileInfo) Type() fs.FileMode  { return i.mode.Type() }
+func (i fileInfo) String() string     { return fs.FormatFileInfo(i) }
+func (i fileInfo) Info() (fs.FileInfo, error) {
+	if i.stat != nil {
+		return i.stat()
+	}
+	return i, nil
+}
+
+// memFile is an opened file with its content in memory.
+type memFile struct {
+	*bytes.Reader
+	info fileInfo
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *memFile) Close() error {
+	return nil
+}
+
+// memDir is an opened directory with its entries in memory.
+type memDir struct {
+	info    fileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *memDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *memDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: errIsDir}
+}
+
+func (d *memDir) Close() error {
+	return nil
+}
+
+func (d *memDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	entries := d.entries[d.offset:]
+	if n > 0 {
+		if len(entries) == 0 {
+			return nil, io.EOF
+		}
+		entries = entries[:min(n, len(entries))]
+	}
+	d.offset += len(entries)
+	return entries, nil
+}
+
+// readerAt returns f as an io.ReaderAt, or reads it into memory, if it is none.
+func readerAt(f fs.File) (io.ReaderAt, error) {
+	if r, ok := f.(io.ReaderAt); ok {
+		return r, nil
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+// pathError returns err as an error of op on the file with the given name. The path of an
+// fs.PathError in err is replaced, since it is relative to another file system.
+func pathError(op, name string, err error) error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	}
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
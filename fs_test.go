@@ -0,0 +1,33 @@
+package nbtreader
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io/fs"
+	"testing"
+)
+
+func TestRegionFSDecompressedLimit(t *testing.T) {
+	// a chunk with 16 MiB of zeros, that is compressed to a few KiB
+	var chunk bytes.Buffer
+	gw := gzip.NewWriter(&chunk)
+	gw.Write(make([]byte, 16<<20))
+	gw.Close()
+
+	sectors := (5 + chunk.Len() + regionSectorSize - 1) / regionSectorSize
+	region := make([]byte, (2+sectors)*regionSectorSize)
+	binary.BigEndian.PutUint32(region, uint32(2<<8|sectors))
+	binary.BigEndian.PutUint32(region[2*regionSectorSize:], uint32(chunk.Len()+1))
+	region[2*regionSectorSize+4] = chunkGZIP
+	copy(region[2*regionSectorSize+5:], chunk.Bytes())
+
+	r, err := OpenRegion(bytes.NewReader(region), WithLimits(Limits{MaxDecompressedSize: 1 << 20}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = fs.ReadFile(r.FS(), "c.0.0.nbt"); !errors.Is(err, ErrDecompressedLimit) {
+		t.Errorf("reading the chunk returned %v, want %v", err, ErrDecompressedLimit)
+	}
+}
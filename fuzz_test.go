@@ -0,0 +1,146 @@
+package nbtreader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fuzzLimits keeps the fuzzed inputs from using more memory than the tests may.
+var fuzzLimits = Limits{MaxBytes: 1 << 22, MaxDepth: MaxDepth, MaxLength: 1 << 16, MaxDecompressedSize: 1 << 22}
+
+// seedFiles returns the files in the directory files, compressed as stored and uncompressed.
+func seedFiles(f *testing.F) [][]byte {
+	names, err := filepath.Glob("files/*.nbt")
+	if err != nil {
+		f.Fatal(err)
+	}
+	var seeds [][]byte
+	for _, name := range names {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			f.Fatal(err)
+		}
+		seeds = append(seeds, data)
+		nbt, err := New(bytes.NewReader(data), nil)
+		if err != nil {
+			f.Fatal(err)
+		}
+		for _, c := range []Compression{NONE, LZ4} {
+			var buf bytes.Buffer
+			nbt.w = &buf
+			nbt.rw.Writer.Reset(&buf)
+			if err := nbt.Compose(c); err != nil {
+				f.Fatal(err)
+			}
+			seeds = append(seeds, buf.Bytes())
+		}
+	}
+	return seeds
+}
+
+func FuzzNew(f *testing.F) {
+	for _, seed := range seedFiles(f) {
+		f.Add(seed, false)
+	}
+	f.Fuzz(func(t *testing.T, data []byte, littleEndian bool) {
+		opts := []Option{WithLimits(fuzzLimits)}
+		if littleEndian {
+			opts = append(opts, ByteOrder(binary.LittleEndian))
+		}
+		nbt, err := New(bytes.NewReader(data), io.Discard, opts...)
+		if err != nil {
+			return
+		}
+		// a parsed tree must be written again
+		if err := nbt.Compose(NONE); err != nil {
+			t.Fatalf("composing a parsed tree: %v", err)
+		}
+	})
+}
+
+func FuzzParseSNBT(f *testing.F) {
+	for _, seed := range seedFiles(f) {
+		nbt, err := New(bytes.NewReader(seed), nil)
+		if err != nil {
+			continue
+		}
+		snbt, err := MarshalSNBT(nbt.Root())
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(snbt)
+	}
+	f.Add([]byte(`{a:[B;1b,2b],b:[I;],c:"ä",d:1.5e3d,e:[{}, {}]}`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		tag, err := ParseSNBT(data)
+		if err != nil {
+			return
+		}
+		// the SNBT of a parsed tree is read back to the same tree
+		snbt, err := MarshalSNBT(tag)
+		if err != nil {
+			t.Fatalf("marshalling a parsed tree: %v", err)
+		}
+		back, err := ParseSNBT(snbt)
+		if err != nil {
+			t.Fatalf("parsing %s: %v", snbt, err)
+		}
+		if !Equal(tag, back) {
+			t.Fatalf("%s is parsed to another tree", snbt)
+		}
+	})
+}
+
+func FuzzDecoderToken(f *testing.F) {
+	for _, seed := range seedFiles(f) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		dec := NewDecoder(bytes.NewReader(data), WithLimits(fuzzLimits))
+		for i := 0; ; i++ {
+			tok, err := dec.Token()
+			if err != nil {
+				return
+			}
+			// skip every other compound and list to cover Skip as well
+			if i%2 == 1 && (tok.Type == Tag_Compound || tok.Type == Tag_List) {
+				if err := dec.Skip(); err != nil {
+					return
+				}
+			}
+		}
+	})
+}
+
+func FuzzLZ4(f *testing.F) {
+	for _, seed := range seedFiles(f) {
+		var buf bytes.Buffer
+		w := newLZ4Writer(&buf)
+		w.Write(seed)
+		w.Close()
+		f.Add(buf.Bytes())
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r := newLZ4Reader(bytes.NewReader(data))
+		r.max = 1 << 22
+		decompressed, err := io.ReadAll(r)
+		if err != nil {
+			return
+		}
+		// valid data is compressed and decompressed again
+		var buf bytes.Buffer
+		w := newLZ4Writer(&buf)
+		w.Write(decompressed)
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		back, err := io.ReadAll(newLZ4Reader(&buf))
+		if err != nil || !bytes.Equal(back, decompressed) {
+			t.Fatalf("round trip of %d bytes: %v", len(decompressed), err)
+		}
+	})
+}
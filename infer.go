@@ -0,0 +1,317 @@
+package nbtreader
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	// inferMaxSamples is the number of distinct sample values kept per tag for the report.
+	inferMaxSamples = 5
+	// inferMaxEnum is the maximum number of distinct string values, that are still inferred as
+	// an enum.
+	inferMaxEnum = 16
+)
+
+// Inference collects statistics about the structure of many trees of tags, e.g. all player files
+// or all chunks of a world, and infers a merged [Schema] from them.
+type Inference struct {
+	root  *inferredTag
+	trees int
+}
+
+// inferredTag holds the statistics of all tags seen at the same place in the trees.
+type inferredTag struct {
+	count int
+	types map[TagType]int
+
+	// compounds
+	compounds int
+	keys      map[String]*inferredTag
+	keyOrder  []String
+
+	// lists
+	elements *inferredTag
+
+	// strings, lists, arrays and compounds
+	minLength, maxLength int
+	hasLength            bool
+
+	// numbers
+	min, max  float64
+	hasNumber bool
+
+	// strings
+	values        map[String]int
+	tooManyValues bool
+	samples       []String
+	numSamples    []NbtTag
+}
+
+func newInferredTag() *inferredTag {
+	return &inferredTag{types: map[TagType]int{}}
+}
+
+// NewInference returns an empty inference.
+func NewInference() *Inference {
+	return &Inference{root: newInferredTag()}
+}
+
+// Add adds the structure of tree to the statistics.
+func (inf *Inference) Add(tree NbtTag) {
+	inf.trees++
+	inf.root.add(tree)
+}
+
+// Trees returns the number of trees added.
+func (inf *Inference) Trees() int {
+	return inf.trees
+}
+
+func (n *inferredTag) add(tag NbtTag) {
+	n.count++
+	n.types[tag.Type()]++
+
+	switch t := tag.(type) {
+	case Compound:
+		n.compounds++
+		n.addLength(len(t))
+		if n.keys == nil {
+			n.keys = map[String]*inferredTag{}
+		}
+		for _, k := range t.Keys() {
+			child, ok := n.keys[k]
+			if !ok {
+				child = newInferredTag()
+				n.keys[k] = child
+				n.keyOrder = append(n.keyOrder, k)
+			}
+			child.add(t[k].Value)
+		}
+	case List:
+		n.addLength(len(t.Elements))
+		if n.elements == nil && len(t.Elements) > 0 {
+			n.elements = newInferredTag()
+		}
+		for _, entry := range t.Elements {
+			n.elements.add(entry)
+		}
+	case ByteArray:
+		n.addLength(len(t))
+	case IntArray:
+		n.addLength(len(t))
+	case LongArray:
+		n.addLength(len(t))
+	case String:
+		n.addLength(len(t))
+		if !n.tooManyValues {
+			if n.values == nil {
+				n.values = map[String]int{}
+			}
+			if n.values[t]++; len(n.values) > inferMaxEnum {
+				n.tooManyValues = true
+				n.values = nil
+			}
+		}
+		if len(n.samples) < inferMaxSamples && !slices.Contains(n.samples, t) {
+			n.samples = append(n.samples, t)
+		}
+	case Byte:
+		n.addNumber(t, float64(t))
+	case Short:
+		n.addNumber(t, float64(t))
+	case Int:
+		n.addNumber(t, float64(t))
+	case Long:
+		n.addNumber(t, float64(t))
+	case Float:
+		n.addNumber(t, float64(t))
+	case Double:
+		n.addNumber(t, float64(t))
+	}
+}
+
+func (n *inferredTag) addLength(l int) {
+	if !n.hasLength {
+		n.minLength, n.maxLength, n.hasLength = l, l, true
+		return
+	}
+	n.minLength, n.maxLength = min(n.minLength, l), max(n.maxLength, l)
+}
+
+func (n *inferredTag) addNumber(tag NbtTag, f float64) {
+	if math.IsNaN(f) {
+		return
+	}
+	if !n.hasNumber {
+		n.min, n.max, n.hasNumber = f, f, true
+	} else {
+		n.min, n.max = math.Min(n.min, f), math.Max(n.max, f)
+	}
+	if len(n.numSamples) < inferMaxSamples && !slices.ContainsFunc(n.numSamples, func(s NbtTag) bool { return Equal(s, tag) }) {
+		n.numSamples = append(n.numSamples, tag)
+	}
+}
+
+// Schema returns the schema inferred from all added trees. Keys, that exist in every compound at
+// their place, are required. Numbers get their observed range, strings with only a few distinct
+// values, that repeat, become an enum.
+func (inf *Inference) Schema() *Schema {
+	if inf.trees == 0 {
+		return &Schema{}
+	}
+	s := inf.root.schema()
+	s.Description = fmt.Sprintf("inferred from %d files", inf.trees)
+	return s
+}
+
+func (n *inferredTag) schema() *Schema {
+	s := &Schema{}
+	if len(n.types) != 1 {
+		// mixed types can only be described as any type
+		return s
+	}
+	for t := range n.types {
+		s.Type = t
+	}
+
+	switch s.Type {
+	case Tag_Compound:
+		s.Keys = map[string]*Schema{}
+		for _, k := range n.keyOrder {
+			child := n.keys[k]
+			s.Keys[string(k)] = child.schema()
+			if child.count == n.compounds {
+				s.Required = append(s.Required, k)
+			}
+		}
+	case Tag_List:
+		if n.elements != nil {
+			s.Elements = n.elements.schema()
+		}
+	case Tag_String:
+		if !n.tooManyValues && len(n.values) > 0 && n.count >= 2*len(n.values) {
+			for _, v := range n.sortedValues() {
+				s.Enum = append(s.Enum, string(v))
+			}
+		}
+	case Tag_Byte, Tag_Short, Tag_Int, Tag_Long, Tag_Float, Tag_Double:
+		if n.hasNumber {
+			minValue, maxValue := n.min, n.max
+			s.Min, s.Max = &minValue, &maxValue
+		}
+	}
+	return s
+}
+
+// sortedValues returns the distinct string values, the most frequent first.
+func (n *inferredTag) sortedValues() []String {
+	values := make([]String, 0, len(n.values))
+	for v := range n.values {
+		values = append(values, v)
+	}
+	slices.SortFunc(values, func(a, b String) int {
+		if n.values[a] != n.values[b] {
+			return n.values[b] - n.values[a]
+		}
+		return strings.Compare(string(a), string(b))
+	})
+	return values
+}
+
+// WriteReport writes a readable report of the statistics to w. Each line shows a path, how often
+// it exists in its parent compound, its types and the observed lengths, ranges and sample values.
+// Elements of lists are shown with the path suffix "[]".
+func (inf *Inference) WriteReport(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "PATH\tPRESENT\tTYPE\tRANGE\tSAMPLES\n")
+	if inf.trees > 0 {
+		inf.root.report(tw, "(root)", inf.trees)
+	}
+	return tw.Flush()
+}
+
+func (n *inferredTag) report(w io.Writer, path string, parents int) {
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", path, n.presence(parents), n.typeSummary(), n.rangeSummary(), n.sampleSummary())
+
+	prefix := path + "."
+	if path == "(root)" {
+		prefix = ""
+	}
+	for _, k := range n.keyOrder {
+		key := string(k)
+		if !isPlainKey(key) {
+			key = quoteString(key)
+		}
+		n.keys[k].report(w, prefix+key, n.compounds)
+	}
+	if n.elements != nil {
+		n.elements.report(w, path+"[]", 0)
+	}
+}
+
+func (n *inferredTag) presence(parents int) string {
+	if parents == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%% (%d)", 100*float64(n.count)/float64(parents), n.count)
+}
+
+func (n *inferredTag) typeSummary() string {
+	types := make([]TagType, 0, len(n.types))
+	for t := range n.types {
+		types = append(types, t)
+	}
+	slices.Sort(types)
+
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = typeName(t)
+		if len(types) > 1 {
+			names[i] += fmt.Sprintf(" %.0f%%", 100*float64(n.types[t])/float64(n.count))
+		}
+	}
+	summary := strings.Join(names, ", ")
+	if n.elements != nil && len(n.elements.types) == 1 {
+		for t := range n.elements.types {
+			summary += " of " + typeName(t)
+		}
+	}
+	return summary
+}
+
+func (n *inferredTag) rangeSummary() string {
+	var parts []string
+	if n.hasLength {
+		parts = append(parts, fmt.Sprintf("len %d..%d", n.minLength, n.maxLength))
+	}
+	if n.hasNumber {
+		parts = append(parts, strconv.FormatFloat(n.min, 'f', -1, 64)+".."+strconv.FormatFloat(n.max, 'f', -1, 64))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (n *inferredTag) sampleSummary() string {
+	var samples []string
+	if !n.tooManyValues && len(n.values) > 0 {
+		for _, v := range n.sortedValues() {
+			samples = append(samples, fmt.Sprintf("%s (%d)", quoteString(string(v)), n.values[v]))
+		}
+		if len(samples) > inferMaxSamples {
+			samples = append(samples[:inferMaxSamples], "...")
+		}
+	} else {
+		for _, v := range n.samples {
+			samples = append(samples, quoteString(string(v)))
+		}
+		for _, v := range n.numSamples {
+			samples = append(samples, v.String())
+		}
+	}
+	return strings.Join(samples, ", ")
+}
@@ -0,0 +1,277 @@
+package nbtreader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// OnlyPaths decodes only the tags at the given paths, with their parents and everything inside
+// them. All other tags are skipped by their length, without decoding them, and are left out of
+// the tree. Skipped list elements are left out as well, so the indices of the other elements
+// change. Use [KeepSkipped] to keep them as [RawTag].
+func OnlyPaths(paths ...Path) Option {
+	return func(o *options) {
+		o.only = append(o.only, paths...)
+	}
+}
+
+// KeepSkipped keeps the tags, that are skipped because of [OnlyPaths], as [RawTag], so they can
+// be decoded later and are written back unchanged.
+func KeepSkipped() Option {
+	return func(o *options) {
+		o.keepSkipped = true
+	}
+}
+
+// RawTag is a tag, that is not decoded. It holds the binary payload of the tag, without its type
+// and name, and is written back unchanged when composed in the same byte order. Use
+// [RawTag.Decode] to get the tag.
+type RawTag struct {
+	tagType TagType
+	data    []byte
+	// order is the byte order of data, nil for big-endian
+	order binary.ByteOrder
+}
+
+// NewRawTag returns a RawTag of type tagType with the binary payload data in big-endian byte
+// order.
+func NewRawTag(tagType TagType, data []byte) RawTag {
+	return RawTag{tagType: tagType, data: data}
+}
+
+// Type returns the type of the undecoded tag.
+func (t RawTag) Type() TagType {
+	return t.tagType
+}
+
+// Bytes returns the binary payload of the tag. It must not be modified.
+func (t RawTag) Bytes() []byte {
+	return t.data
+}
+
+// Decode decodes the tag.
+func (t RawTag) Decode() (NbtTag, error) {
+	d := newDecoder(bytes.NewReader(t.data), options{order: t.order})
+	tag, err := parseType(d, t.tagType)
+	if err != nil {
+		return nil, err
+	}
+	if d.offset != int64(len(t.data)) {
+		return nil, &ParseError{Offset: d.offset, Type: t.tagType, Err: ErrTrailingData}
+	}
+	return tag, nil
+}
+
+// String implements the fmt.Stringer interface. The tag is decoded to get its SNBT string.
+func (t RawTag) String() string {
+	tag, err := t.Decode()
+	if err != nil {
+		return fmt.Sprintf("<raw %s: %v>", t.tagType, err)
+	}
+	return tag.String()
+}
+
+func (t RawTag) MarshalJSON() ([]byte, error) {
+	tag, err := t.Decode()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(tag)
+}
+
+func (t RawTag) MarshalNJSON() ([]byte, error) {
+	tag, err := t.Decode()
+	if err != nil {
+		return nil, err
+	}
+	return MarshalNJSON(tag)
+}
+
+func (t RawTag) parse(d *decoder) (NbtTag, error) {
+	var buf bytes.Buffer
+	if err := d.skipTag(t.tagType, &buf); err != nil {
+		return t, err
+	}
+	t.data = buf.Bytes()
+	if d.order != binary.BigEndian {
+		t.order = d.order
+	}
+	return t, d.account(int64(len(t.data)))
+}
+
+func (t RawTag) compose(e *encoder) error {
+	if (options{order: t.order}).byteOrder() != byteOrder(e) {
+		// the payload is converted to the other byte order by decoding it
+		tag, err := t.Decode()
+		if err != nil {
+			return err
+		}
+		return tag.compose(e)
+	}
+	_, err := e.Write(t.data)
+	return err
+}
+
+// wanted reports whether the tag at the current path is decoded with the paths of [OnlyPaths]:
+// it is one of the paths, a parent of one or inside of one.
+func (d *decoder) wanted() bool {
+	for _, p := range d.only {
+		n := min(len(p), len(d.path))
+		match := true
+		for i := 0; i < n && match; i++ {
+			match = p[i] == d.path[i]
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// skipTag reads over the payload of a tag of type tagType by the length prefixes, without decoding
+// it. The data is copied to w, if it is not nil.
+func (d *decoder) skipTag(tagType TagType, w io.Writer) error {
+	return d.skipper(w).skip(tagType, 0)
+}
+
+func (d *decoder) skipper(w io.Writer) skipper {
+	s := skipper{d: d, r: d}
+	if w != nil {
+		s.r = io.TeeReader(d, w)
+	}
+	return s
+}
+
+// skipper reads over tags by their length prefixes.
+type skipper struct {
+	d *decoder
+	r io.Reader
+}
+
+// skip reads over a tag of type tagType, depth levels below the current path.
+func (s skipper) skip(tagType TagType, depth int) error {
+	if size := fixedSize(tagType); size > 0 {
+		return s.discard(size)
+	}
+	switch tagType {
+	case Tag_String:
+		n, err := s.popShort()
+		if err != nil {
+			return err
+		}
+		return s.discard(int64(uint16(n)))
+	case Tag_Byte_Array, Tag_Int_Array, Tag_Long_Array:
+		n, err := s.popInt()
+		if err != nil {
+			return err
+		}
+		if err = s.d.checkLength(n); err != nil {
+			return err
+		}
+		return s.discard(int64(n) * arrayElemSize(tagType))
+	case Tag_List:
+		if err := s.d.checkDepthAt(len(s.d.path) + depth); err != nil {
+			return err
+		}
+		elemType, err := popByte(s.r)
+		if err != nil {
+			return err
+		}
+		n, err := s.popInt()
+		if err != nil {
+			return err
+		}
+		if err = s.d.checkLength(n); err != nil {
+			return err
+		}
+		return s.elements(TagType(elemType), int(n), depth+1)
+	case Tag_Compound:
+		if err := s.d.checkDepthAt(len(s.d.path) + depth); err != nil {
+			return err
+		}
+		return s.entries(depth + 1)
+	default:
+		return fmt.Errorf("unknown type 0x%02x", byte(tagType))
+	}
+}
+
+// elements reads over n list elements of type elemType.
+func (s skipper) elements(elemType TagType, n int, depth int) error {
+	if n > 0 && elemType == Tag_End {
+		return fmt.Errorf("list cannot be of type TAG_END")
+	}
+	if size := fixedSize(elemType); size > 0 {
+		return s.discard(int64(n) * size)
+	}
+	for i := 0; i < n; i++ {
+		if err := s.skip(elemType, depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// entries reads over the entries of a compound up to its end.
+func (s skipper) entries(depth int) error {
+	for {
+		t, err := popByte(s.r)
+		if err != nil {
+			return err
+		}
+		if TagType(t) == Tag_End {
+			return nil
+		}
+		n, err := s.popShort()
+		if err != nil {
+			return err
+		}
+		if err = s.discard(int64(uint16(n))); err != nil {
+			return err
+		}
+		if err = s.skip(TagType(t), depth); err != nil {
+			return err
+		}
+	}
+}
+
+// popShort and popInt read the lengths of strings, lists and arrays in the byte order of the
+// decoder, as s.r may be a reader of its own.
+func (s skipper) popShort() (Short, error) {
+	b, err := readN(s.r, 2)
+	if err != nil {
+		return 0, err
+	}
+	return Short(s.d.order.Uint16(b)), nil
+}
+
+func (s skipper) popInt() (Int, error) {
+	b, err := readN(s.r, 4)
+	if err != nil {
+		return 0, err
+	}
+	return Int(s.d.order.Uint32(b)), nil
+}
+
+func (s skipper) discard(n int64) error {
+	_, err := io.CopyN(io.Discard, s.r, n)
+	return err
+}
+
+// fixedSize returns the size of the payload of numbers, or 0 for other types.
+func fixedSize(tagType TagType) int64 {
+	switch tagType {
+	case Tag_Byte:
+		return 1
+	case Tag_Short:
+		return 2
+	case Tag_Int, Tag_Float:
+		return 4
+	case Tag_Long, Tag_Double:
+		return 8
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,147 @@
+package nbtreader
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Errors returned, wrapped in a [*ParseError], when parsing exceeds one of the [Limits]. Use
+// errors.Is to check for them.
+var (
+	ErrSizeLimit         = errors.New("size limit exceeded")
+	ErrDepthLimit        = errors.New("depth limit exceeded")
+	ErrLengthLimit       = errors.New("length limit exceeded")
+	ErrDecompressedLimit = errors.New("decompressed size limit exceeded")
+)
+
+// Limits restricts the resources used to parse untrusted data, like Minecraft's NbtAccounter.
+// A zero field means no limit, except for MaxDepth.
+type Limits struct {
+	// MaxBytes is the maximum estimated memory size of the parsed tree. The size of each tag is
+	// accounted like Minecraft does it, e.g. 12 bytes for an int and 24 bytes plus the elements
+	// for an array.
+	MaxBytes int64
+	// MaxDepth is the maximum nesting depth of compounds and lists. It defaults to [MaxDepth],
+	// as deeper trees are rejected by Minecraft and can't be composed again.
+	MaxDepth int
+	// MaxLength is the maximum number of elements of a single list or array.
+	MaxLength int
+	// MaxDecompressedSize is the maximum number of bytes read after decompression. It protects
+	// against compression bombs.
+	MaxDecompressedSize int64
+}
+
+// WithLimits sets the limits for parsing the data, e.g.
+//
+//	nbt, err := nbtreader.New(upload, nil, nbtreader.WithLimits(nbtreader.Limits{
+//		MaxBytes:            2 << 20,
+//		MaxLength:           1 << 16,
+//		MaxDecompressedSize: 4 << 20,
+//	}))
+//	if errors.Is(err, nbtreader.ErrSizeLimit) {
+//		...
+//	}
+func WithLimits(l Limits) Option {
+	return func(o *options) {
+		o.limits = l
+	}
+}
+
+// maxPrealloc is the maximum number of elements allocated for a list or array before they are
+// actually read, so a forged length can't allocate more memory than the data contains.
+const maxPrealloc = 1 << 12
+
+// accounted sizes of the tags, as used by Minecraft's NbtAccounter
+const (
+	sizeArray         = 24
+	sizeString        = 36
+	sizeList          = 37
+	sizeCompound      = 48
+	sizeCompoundEntry = 28
+)
+
+// tagSize returns the accounted size of a tag of type tagType without its elements or content.
+func tagSize(tagType TagType) int64 {
+	switch tagType {
+	case Tag_Byte:
+		return 9
+	case Tag_Short:
+		return 10
+	case Tag_Int, Tag_Float:
+		return 12
+	case Tag_Long, Tag_Double:
+		return 16
+	case Tag_Byte_Array, Tag_Int_Array, Tag_Long_Array:
+		return sizeArray
+	case Tag_String:
+		return sizeString
+	case Tag_List:
+		return sizeList
+	case Tag_Compound:
+		return sizeCompound
+	default:
+		return 0
+	}
+}
+
+// account adds n bytes to the accounted size of the tree and fails if it exceeds the limit.
+func (d *decoder) account(n int64) error {
+	d.size += n
+	if d.limits.MaxBytes > 0 && d.size > d.limits.MaxBytes {
+		return fmt.Errorf("%w: tree needs more than %d bytes", ErrSizeLimit, d.limits.MaxBytes)
+	}
+	return nil
+}
+
+// checkLength fails if length exceeds the limit for lists and arrays.
+func (d *decoder) checkLength(length Int) error {
+	if length < 0 {
+		return fmt.Errorf("negative length %d", length)
+	}
+	if d.limits.MaxLength > 0 && int64(length) > int64(d.limits.MaxLength) {
+		return fmt.Errorf("%w: length %d exceeds maximum of %d", ErrLengthLimit, length, d.limits.MaxLength)
+	}
+	return nil
+}
+
+// checkDepth fails if a compound or list at the current path would exceed the depth limit.
+func (d *decoder) checkDepth() error {
+	return d.checkDepthAt(len(d.path))
+}
+
+// checkDepthAt fails if a compound or list at the given depth would exceed the depth limit.
+func (d *decoder) checkDepthAt(depth int) error {
+	maxDepth := d.limits.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = MaxDepth
+	}
+	if depth >= maxDepth {
+		return fmt.Errorf("%w: exceeds maximum depth of %d", ErrDepthLimit, maxDepth)
+	}
+	return nil
+}
+
+// limitedReader reads from r and fails with ErrDecompressedLimit when more than n bytes are read.
+type limitedReader struct {
+	r   io.Reader
+	n   int64
+	max int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		// check if there is more data, before reporting the limit
+		var buf [1]byte
+		if n, err := l.r.Read(buf[:]); n == 0 {
+			return 0, err
+		}
+		return 0, fmt.Errorf("%w: data is larger than %d bytes", ErrDecompressedLimit, l.max)
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
@@ -0,0 +1,153 @@
+package nbtreader
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"unsafe"
+)
+
+// Lossless keeps all details of the parsed data, that are not part of the tree, so an unmodified
+// NBT object is written byte-exact by [NBT.NBT]. This includes
+//
+//   - entries of compounds, that are overwritten by a later entry with the same key,
+//   - the element type of empty lists,
+//   - data after the root tag,
+//   - the gzip header and the compressed data itself, as the output of the compressor may differ.
+//
+// Because the overwritten entries keep their position, a duplicate key is placed at the position
+// of its last entry in the compound, instead of its first one.
+//
+// The compressed data is only reused, if the composed data is the same as the parsed one.
+// Otherwise it is compressed again, with the original gzip header.
+func Lossless() Option {
+	return func(o *options) {
+		o.lossless = true
+	}
+}
+
+// lossless holds the details of parsed data, that are needed to write it byte-exact.
+type lossless struct {
+	// duplicates holds the overwritten entries of compounds by the compound, see compoundID,
+	// sorted by their position
+	duplicates map[unsafe.Pointer][]duplicate
+	// trailing is the data after the root tag
+	trailing []byte
+
+	// raw is the original compressed data and sum the hash of the decompressed data
+	raw        bytes.Buffer
+	sum        [sha256.Size]byte
+	gzipHeader *gzip.Header
+}
+
+// duplicate is an entry of a compound, that was overwritten by a later entry with the same key.
+type duplicate struct {
+	// pos is the position of the entry in the compound, counting all entries
+	pos   int
+	key   String
+	value NbtTag
+}
+
+// addDuplicate records the overwritten entry of the compound t.
+func (d *decoder) addDuplicate(t Compound, dup duplicate) {
+	if d.duplicates == nil {
+		d.duplicates = map[unsafe.Pointer][]duplicate{}
+	}
+	id := compoundID(t)
+	d.duplicates[id] = append(d.duplicates[id], dup)
+	slices.SortFunc(d.duplicates[id], func(a, b duplicate) int { return a.pos - b.pos })
+}
+
+// compoundID identifies the map of the compound t. Overwritten entries are recorded by the
+// compound itself instead of its path, as the overwritten entries can be compounds at the same
+// path.
+func compoundID(t Compound) unsafe.Pointer {
+	return reflect.ValueOf(t).UnsafePointer()
+}
+
+// Divergence describes the first difference between some data and the data written after
+// parsing it in lossless mode.
+type Divergence struct {
+	// Offset is the position of the first difference. It is in the decompressed data, unless
+	// Compressed is set.
+	Offset int64
+	// Path is the path of the tag at Offset in the original data.
+	Path Path
+	// Compressed reports, that the decompressed data is the same and only the compressed data
+	// differs.
+	Compressed bool
+}
+
+// Error implements the error interface.
+func (d *Divergence) Error() string {
+	if d.Compressed {
+		return fmt.Sprintf("nbt: compressed data differs at offset %d", d.Offset)
+	}
+	return fmt.Sprintf("nbt: data differs at offset %d in %s", d.Offset, d.Path.Display())
+}
+
+// VerifyRoundTrip parses data in lossless mode, writes it again and compares the result with
+// data. It returns a *[Divergence] if they differ, or any error while parsing or writing. The
+// options are used for parsing, e.g. [ByteOrder] or [BedrockLevel] for Bedrock data.
+func VerifyRoundTrip(data []byte, opts ...Option) error {
+	var out bytes.Buffer
+	nbt, err := New(bytes.NewReader(data), &out, append(opts[:len(opts):len(opts)], Lossless())...)
+	if err != nil {
+		return err
+	}
+	if err = nbt.Compose(nbt.Compression()); err != nil {
+		return err
+	}
+	if bytes.Equal(out.Bytes(), data) {
+		return nil
+	}
+
+	// compare the decompressed data to find the tag, that differs
+	src := &NBT{rw: bufio.NewReadWriter(bufio.NewReader(bytes.NewReader(data)), nil)}
+	if err = src.decompress(); err != nil {
+		return fmt.Errorf("nbt: %v", err)
+	}
+	original, err := io.ReadAll(src.rw)
+	if err != nil {
+		return fmt.Errorf("nbt: %v", err)
+	}
+	var composed bytes.Buffer
+	nbt.rw = bufio.NewReadWriter(nil, bufio.NewWriter(&composed))
+	if err = nbt.Compose(NONE); err != nil {
+		return err
+	}
+
+	offset := firstDifference(original, composed.Bytes())
+	if offset < 0 {
+		return &Divergence{Offset: firstDifference(data, out.Bytes()), Compressed: true}
+	}
+	div := &Divergence{Offset: offset}
+	// the data up to the difference fails to parse in the tag at the offset
+	_, err = New(bytes.NewReader(original[:offset]), nil, opts...)
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		div.Path = parseErr.Path
+	}
+	return div
+}
+
+// firstDifference returns the offset of the first byte, that differs in a and b, or -1 if they are
+// equal.
+func firstDifference(a, b []byte) int64 {
+	n := min(len(a), len(b))
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return int64(i)
+		}
+	}
+	if len(a) == len(b) {
+		return -1
+	}
+	return int64(n)
+}
@@ -0,0 +1,46 @@
+package nbtreader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// nbtEntry returns the binary NBT of a tag header with the given type and name.
+func nbtEntry(tagType TagType, name string) []byte {
+	b := []byte{byte(tagType), 0, 0}
+	binary.BigEndian.PutUint16(b[1:], uint16(len(name)))
+	return append(b, name...)
+}
+
+func TestVerifyRoundTripNestedDuplicates(t *testing.T) {
+	intEntry := func(name string, v int32) []byte {
+		return binary.BigEndian.AppendUint32(nbtEntry(Tag_Int, name), uint32(v))
+	}
+	// {a:{x:1,x:2},a:{x:3}}, where the overwritten compound has duplicates of its own
+	var data []byte
+	data = append(data, nbtEntry(Tag_Compound, "")...)
+	data = append(data, nbtEntry(Tag_Compound, "a")...)
+	data = append(data, intEntry("x", 1)...)
+	data = append(data, intEntry("x", 2)...)
+	data = append(data, byte(Tag_End))
+	data = append(data, nbtEntry(Tag_Compound, "a")...)
+	data = append(data, intEntry("x", 3)...)
+	data = append(data, byte(Tag_End), byte(Tag_End))
+
+	if err := VerifyRoundTrip(data); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	nbt, err := New(bytes.NewReader(data), &out, Lossless())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = nbt.Compose(NONE); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), data) {
+		t.Errorf("composed %x, want %x", out.Bytes(), data)
+	}
+}
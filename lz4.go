@@ -0,0 +1,356 @@
+package nbtreader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// LZ4 data is stored in the block stream format of lz4-java's LZ4BlockOutputStream, which
+// Minecraft uses for region chunks since 1.20.5. Each block has a header of
+//
+//	magic "LZ4Block" | token | compressed length | decompressed length | checksum
+//
+// with the lengths and the checksum as little endian int32. The stream ends with an empty block.
+
+var lz4Magic = []byte("LZ4Block")
+
+const (
+	lz4HeaderSize = 8 + 1 + 4 + 4 + 4
+	lz4BlockSize  = 1 << 16
+	// lz4Level is the compression level of the token, derived from the block size
+	lz4Level = 16 - 10
+
+	lz4MethodRaw = 0x10
+	lz4MethodLZ4 = 0x20
+
+	lz4Seed = 0x9747b28c
+)
+
+// lz4Reader decompresses an LZ4 block stream.
+type lz4Reader struct {
+	r    io.Reader
+	buf  bytes.Buffer
+	data []byte
+	done bool
+	// err is returned again by all reads after an error
+	err error
+
+	// max limits the decompressed size, if it is greater than 0, n is the size so far
+	max, n int64
+}
+
+func newLZ4Reader(r io.Reader) *lz4Reader {
+	return &lz4Reader{r: r}
+}
+
+func (z *lz4Reader) Read(p []byte) (int, error) {
+	for len(z.data) == 0 {
+		if z.done {
+			return 0, io.EOF
+		}
+		if z.err != nil {
+			return 0, z.err
+		}
+		if z.err = z.readBlock(); z.err != nil {
+			z.data = nil
+		}
+	}
+	n := copy(p, z.data)
+	z.data = z.data[n:]
+	return n, nil
+}
+
+// readBlock reads and decompresses the next block into z.data.
+func (z *lz4Reader) readBlock() error {
+	var header [lz4HeaderSize]byte
+	if _, err := io.ReadFull(z.r, header[:]); err != nil {
+		return fmt.Errorf("lz4: reading block header: %w", noEOF(err))
+	}
+	if !bytes.Equal(header[:8], lz4Magic) {
+		return errors.New("lz4: invalid block magic")
+	}
+	method := header[8] & 0xf0
+	compressedLen := int32(binary.LittleEndian.Uint32(header[9:]))
+	length := int32(binary.LittleEndian.Uint32(header[13:]))
+	checksum := binary.LittleEndian.Uint32(header[17:])
+	if length < 0 || compressedLen < 0 || length > 1<<(header[8]&0x0f+10) || compressedLen > lz4CompressBound(length) || (method == lz4MethodRaw && compressedLen != length) {
+		return fmt.Errorf("lz4: invalid block lengths %d and %d", compressedLen, length)
+	}
+	if length == 0 {
+		// the empty block ends the stream
+		z.done = true
+		return nil
+	}
+	if z.n += int64(length); z.max > 0 && z.n > z.max {
+		return fmt.Errorf("%w: data is larger than %d bytes", ErrDecompressedLimit, z.max)
+	}
+
+	// the buffer grows with the data read, instead of trusting the length of the header
+	z.buf.Reset()
+	if _, err := io.CopyN(&z.buf, z.r, int64(compressedLen)); err != nil {
+		return fmt.Errorf("lz4: reading block: %w", noEOF(err))
+	}
+	compressed := z.buf.Bytes()
+
+	switch method {
+	case lz4MethodRaw:
+		z.data = append(z.data[:0], compressed...)
+	case lz4MethodLZ4:
+		data, err := lz4DecompressBlock(make([]byte, 0, length), compressed)
+		if err != nil {
+			return err
+		}
+		if len(data) != int(length) {
+			return fmt.Errorf("lz4: block has %d bytes instead of %d", len(data), length)
+		}
+		z.data = data
+	default:
+		return fmt.Errorf("lz4: unknown block method 0x%02x", method)
+	}
+	if xxhash32(z.data, lz4Seed)&0x0fffffff != checksum {
+		return errors.New("lz4: checksum mismatch")
+	}
+	return nil
+}
+
+// lz4CompressBound returns the maximum size of a compressed block of n bytes.
+func lz4CompressBound(n int32) int32 {
+	return n + n/255 + 16
+}
+
+// lz4Writer compresses data to an LZ4 block stream. It must be closed to write the last block
+// and the end of the stream.
+type lz4Writer struct {
+	w   io.Writer
+	buf []byte
+}
+
+func newLZ4Writer(w io.Writer) *lz4Writer {
+	return &lz4Writer{w: w, buf: make([]byte, 0, lz4BlockSize)}
+}
+
+func (z *lz4Writer) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		m := copy(z.buf[len(z.buf):cap(z.buf)], p)
+		z.buf = z.buf[:len(z.buf)+m]
+		p = p[m:]
+		n += m
+		if len(z.buf) == cap(z.buf) {
+			if err := z.writeBlock(z.buf); err != nil {
+				return n, err
+			}
+			z.buf = z.buf[:0]
+		}
+	}
+	return n, nil
+}
+
+// Close writes the buffered data and the empty block, that ends the stream. It does not close the
+// underlying writer.
+func (z *lz4Writer) Close() error {
+	if len(z.buf) > 0 {
+		if err := z.writeBlock(z.buf); err != nil {
+			return err
+		}
+		z.buf = z.buf[:0]
+	}
+	return z.writeBlock(nil)
+}
+
+// writeBlock writes data as a single block. Data, that doesn't get smaller, is stored raw.
+func (z *lz4Writer) writeBlock(data []byte) error {
+	block := lz4CompressBlock(make([]byte, lz4HeaderSize, lz4HeaderSize+len(data)+len(data)/255+16), data)
+	method := byte(lz4MethodLZ4)
+	if len(block)-lz4HeaderSize >= len(data) {
+		block = append(block[:lz4HeaderSize], data...)
+		method = lz4MethodRaw
+	}
+
+	copy(block, lz4Magic)
+	block[8] = method | lz4Level
+	binary.LittleEndian.PutUint32(block[9:], uint32(len(block)-lz4HeaderSize))
+	binary.LittleEndian.PutUint32(block[13:], uint32(len(data)))
+	var checksum uint32
+	if len(data) > 0 {
+		checksum = xxhash32(data, lz4Seed) & 0x0fffffff
+	}
+	binary.LittleEndian.PutUint32(block[17:], checksum)
+	_, err := z.w.Write(block)
+	return err
+}
+
+// lz4DecompressBlock appends the decompressed LZ4 block src to dst. The decompressed data must fit
+// into the capacity of dst.
+func lz4DecompressBlock(dst, src []byte) ([]byte, error) {
+	errCorrupt := errors.New("lz4: corrupt block")
+	start := len(dst)
+	for i := 0; i < len(src); {
+		token := src[i]
+		i++
+
+		literals := int(token >> 4)
+		if literals == 15 {
+			for {
+				if i >= len(src) {
+					return dst, errCorrupt
+				}
+				literals += int(src[i])
+				i++
+				if src[i-1] != 255 {
+					break
+				}
+			}
+		}
+		if literals > len(src)-i || literals > cap(dst)-len(dst) {
+			return dst, errCorrupt
+		}
+		dst = append(dst, src[i:i+literals]...)
+		i += literals
+		if i == len(src) {
+			// the last sequence only has literals
+			break
+		}
+
+		if i+2 > len(src) {
+			return dst, errCorrupt
+		}
+		offset := int(binary.LittleEndian.Uint16(src[i:]))
+		i += 2
+		if offset == 0 || offset > len(dst)-start {
+			return dst, errCorrupt
+		}
+		matchLen := int(token & 0x0f)
+		if matchLen == 15 {
+			for {
+				if i >= len(src) {
+					return dst, errCorrupt
+				}
+				matchLen += int(src[i])
+				i++
+				if src[i-1] != 255 {
+					break
+				}
+			}
+		}
+		matchLen += lz4MinMatch
+		if matchLen > cap(dst)-len(dst) {
+			return dst, errCorrupt
+		}
+		// the match may overlap with the bytes it produces, so it is copied byte by byte
+		pos := len(dst) - offset
+		for j := 0; j < matchLen; j++ {
+			dst = append(dst, dst[pos+j])
+		}
+	}
+	return dst, nil
+}
+
+const (
+	lz4MinMatch     = 4
+	lz4LastLiterals = 5
+	lz4MFLimit      = 12
+	lz4HashBits     = 14
+)
+
+// lz4CompressBlock appends src compressed as LZ4 block to dst, using a greedy match search.
+func lz4CompressBlock(dst, src []byte) []byte {
+	var table [1 << lz4HashBits]int32
+	anchor := 0
+	for i := 0; i < len(src)-lz4MFLimit; {
+		seq := binary.LittleEndian.Uint32(src[i:])
+		h := (seq * 2654435761) >> (32 - lz4HashBits)
+		ref := int(table[h]) - 1
+		table[h] = int32(i + 1)
+		if ref < 0 || i-ref > 0xffff || binary.LittleEndian.Uint32(src[ref:]) != seq {
+			i++
+			continue
+		}
+
+		matchLen := lz4MinMatch
+		for i+matchLen < len(src)-lz4LastLiterals && src[ref+matchLen] == src[i+matchLen] {
+			matchLen++
+		}
+		dst = lz4AppendSequence(dst, src[anchor:i], i-ref, matchLen)
+		i += matchLen
+		anchor = i
+	}
+	return lz4AppendSequence(dst, src[anchor:], 0, 0)
+}
+
+// lz4AppendSequence appends a sequence of literals followed by a match to dst. A matchLen of 0
+// writes the last sequence, that only has literals.
+func lz4AppendSequence(dst, literals []byte, offset, matchLen int) []byte {
+	token := byte(min(len(literals), 15)) << 4
+	if matchLen > 0 {
+		token |= byte(min(matchLen-lz4MinMatch, 15))
+	}
+	dst = append(dst, token)
+	if len(literals) >= 15 {
+		dst = lz4AppendLength(dst, len(literals)-15)
+	}
+	dst = append(dst, literals...)
+	if matchLen == 0 {
+		return dst
+	}
+	dst = binary.LittleEndian.AppendUint16(dst, uint16(offset))
+	if matchLen-lz4MinMatch >= 15 {
+		dst = lz4AppendLength(dst, matchLen-lz4MinMatch-15)
+	}
+	return dst
+}
+
+func lz4AppendLength(dst []byte, n int) []byte {
+	for ; n >= 255; n -= 255 {
+		dst = append(dst, 255)
+	}
+	return append(dst, byte(n))
+}
+
+const (
+	xxPrime1 uint32 = 2654435761
+	xxPrime2 uint32 = 2246822519
+	xxPrime3 uint32 = 3266489917
+	xxPrime4 uint32 = 668265263
+	xxPrime5 uint32 = 374761393
+)
+
+// xxhash32 returns the 32 bit xxHash of b, used for the checksums of LZ4 blocks.
+func xxhash32(b []byte, seed uint32) uint32 {
+	n := len(b)
+	var h uint32
+	if n >= 16 {
+		v1 := seed + xxPrime1 + xxPrime2
+		v2 := seed + xxPrime2
+		v3 := seed
+		v4 := seed - xxPrime1
+		for ; len(b) >= 16; b = b[16:] {
+			v1 = bits.RotateLeft32(v1+binary.LittleEndian.Uint32(b[0:])*xxPrime2, 13) * xxPrime1
+			v2 = bits.RotateLeft32(v2+binary.LittleEndian.Uint32(b[4:])*xxPrime2, 13) * xxPrime1
+			v3 = bits.RotateLeft32(v3+binary.LittleEndian.Uint32(b[8:])*xxPrime2, 13) * xxPrime1
+			v4 = bits.RotateLeft32(v4+binary.LittleEndian.Uint32(b[12:])*xxPrime2, 13) * xxPrime1
+		}
+		h = bits.RotateLeft32(v1, 1) + bits.RotateLeft32(v2, 7) + bits.RotateLeft32(v3, 12) + bits.RotateLeft32(v4, 18)
+	} else {
+		h = seed + xxPrime5
+	}
+
+	h += uint32(n)
+	for ; len(b) >= 4; b = b[4:] {
+		h = bits.RotateLeft32(h+binary.LittleEndian.Uint32(b)*xxPrime3, 17) * xxPrime4
+	}
+	for _, c := range b {
+		h = bits.RotateLeft32(h+uint32(c)*xxPrime5, 11) * xxPrime1
+	}
+
+	h ^= h >> 15
+	h *= xxPrime2
+	h ^= h >> 13
+	h *= xxPrime3
+	h ^= h >> 16
+	return h
+}
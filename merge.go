@@ -0,0 +1,238 @@
+package nbtreader
+
+import "fmt"
+
+// maxMergeListCells limits the size of the table used to match the elements of two lists during a
+// merge. Lists with more differing elements are not merged, but reported as a conflict.
+const maxMergeListCells = 1 << 22
+
+// Conflict is a change to the same tag on both sides of a three-way merge, that could not be
+// merged automatically. Base, Ours and Theirs are nil if the tag does not exist on that side.
+type Conflict struct {
+	Path   Path
+	Base   NbtTag
+	Ours   NbtTag
+	Theirs NbtTag
+}
+
+func (c Conflict) String() string {
+	return fmt.Sprintf("%s: changed on both sides", c.Path)
+}
+
+// Merge does a three-way merge of the trees ours and theirs, which both derive from base. Changes
+// that only happened on one side are taken over, changes on both sides are merged recursively
+// down to single values. Entries of compounds are merged by key, elements of lists by matching
+// them against the base list, so insertions and removals at different places of a list are
+// merged as well.
+//
+// Changes, that could not be merged, are returned as conflicts. For each conflict the merged tree
+// contains the value of ours. The merged tree may share unchanged subtrees with the inputs.
+func Merge(base, ours, theirs NbtTag) (NbtTag, []Conflict) {
+	var conflicts []Conflict
+	merged := merge(&conflicts, Path{}, base, ours, theirs)
+	return merged, conflicts
+}
+
+func merge(conflicts *[]Conflict, p Path, base, ours, theirs NbtTag) NbtTag {
+	switch {
+	case Equal(ours, theirs), Equal(base, theirs):
+		return ours
+	case Equal(base, ours):
+		return theirs
+	}
+
+	switch o := ours.(type) {
+	case Compound:
+		t, okT := theirs.(Compound)
+		b, okB := base.(Compound)
+		if okT && (okB || base == nil) {
+			return mergeCompound(conflicts, p, b, o, t)
+		}
+	case List:
+		t, okT := theirs.(List)
+		b, okB := base.(List)
+		if okT && (okB || base == nil) && sameListType(o, t) && sameListType(b, o) && sameListType(b, t) {
+			return mergeList(conflicts, p, b, o, t)
+		}
+	}
+
+	*conflicts = append(*conflicts, Conflict{Path: p, Base: base, Ours: ours, Theirs: theirs})
+	return ours
+}
+
+// sameListType reports whether the lists a and b have the same element type. Empty lists match
+// any type.
+func sameListType(a, b List) bool {
+	return len(a.Elements) == 0 || len(b.Elements) == 0 || a.TagType == b.TagType
+}
+
+func mergeCompound(conflicts *[]Conflict, p Path, base, ours, theirs Compound) Compound {
+	merged := Compound{}
+	for _, k := range ours.Keys() {
+		if v := merge(conflicts, p.Key(k), entryValue(base, k), ours[k].Value, entryValue(theirs, k)); v != nil {
+			merged.Set(k, v)
+		}
+	}
+	for _, k := range theirs.Keys() {
+		if _, ok := ours[k]; ok {
+			continue
+		}
+		if v := merge(conflicts, p.Key(k), entryValue(base, k), nil, theirs[k].Value); v != nil {
+			merged.Set(k, v)
+		}
+	}
+	return merged
+}
+
+// entryValue returns the value of key in t, or nil if t is nil or has no such key.
+func entryValue(t Compound, key String) NbtTag {
+	entry, ok := t[key]
+	if !ok {
+		return nil
+	}
+	return entry.Value
+}
+
+func mergeList(conflicts *[]Conflict, p Path, base, ours, theirs List) List {
+	merged := List{TagType: ours.TagType}
+	if len(ours.Elements) == 0 {
+		merged.TagType = theirs.TagType
+	}
+
+	b, o, t := base.Elements, ours.Elements, theirs.Elements
+	if len(b) == len(o) && len(o) == len(t) {
+		merged.Elements = mergeElements(conflicts, p, 0, b, o, t)
+		return merged
+	}
+
+	matchO, okO := matchElements(b, o)
+	matchT, okT := matchElements(b, t)
+	if !okO || !okT {
+		*conflicts = append(*conflicts, Conflict{Path: p, Base: base, Ours: ours, Theirs: theirs})
+		return ours
+	}
+
+	// walk through the base list and alternate between stable chunks, where an element is
+	// matched on both sides, and unstable chunks in between, like diff3 does
+	var i, oi, ti int
+	for i < len(b) || oi < len(o) || ti < len(t) {
+		k := 0
+		for i+k < len(b) && matchO[i+k] == oi+k && matchT[i+k] == ti+k {
+			k++
+		}
+		if k > 0 {
+			merged.Elements = append(merged.Elements, o[oi:oi+k]...)
+			i, oi, ti = i+k, oi+k, ti+k
+			continue
+		}
+
+		j := i
+		for j < len(b) && (matchO[j] < 0 || matchT[j] < 0) {
+			j++
+		}
+		oEnd, tEnd := len(o), len(t)
+		if j < len(b) {
+			oEnd, tEnd = matchO[j], matchT[j]
+		}
+
+		bChunk, oChunk, tChunk := b[i:j], o[oi:oEnd], t[ti:tEnd]
+		switch {
+		case equalElements(oChunk, tChunk), equalElements(bChunk, tChunk):
+			merged.Elements = append(merged.Elements, oChunk...)
+		case equalElements(bChunk, oChunk):
+			merged.Elements = append(merged.Elements, tChunk...)
+		case len(bChunk) == len(oChunk) && len(oChunk) == len(tChunk):
+			merged.Elements = append(merged.Elements, mergeElements(conflicts, p, len(merged.Elements), bChunk, oChunk, tChunk)...)
+		default:
+			*conflicts = append(*conflicts, Conflict{
+				Path:   p.Index(len(merged.Elements)),
+				Base:   List{TagType: base.TagType, Elements: bChunk},
+				Ours:   List{TagType: ours.TagType, Elements: oChunk},
+				Theirs: List{TagType: theirs.TagType, Elements: tChunk},
+			})
+			merged.Elements = append(merged.Elements, oChunk...)
+		}
+		i, oi, ti = j, oEnd, tEnd
+	}
+	return merged
+}
+
+// mergeElements merges the lists of elements of the same length one by one. The paths of the
+// elements start at the index offset.
+func mergeElements(conflicts *[]Conflict, p Path, offset int, base, ours, theirs []NbtTag) []NbtTag {
+	merged := make([]NbtTag, len(ours))
+	for i := range ours {
+		merged[i] = merge(conflicts, p.Index(offset+i), base[i], ours[i], theirs[i])
+	}
+	return merged
+}
+
+func equalElements(a, b []NbtTag) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchElements matches the elements of the lists a and b by their longest common subsequence.
+// For each element of a it returns the index of the matching element of b or -1. It reports false
+// if the lists are too large to be matched.
+func matchElements(a, b []NbtTag) ([]int, bool) {
+	match := make([]int, len(a))
+	for i := range match {
+		match[i] = -1
+	}
+
+	// common prefix and suffix are matched directly, only the rest needs the table
+	start := 0
+	for start < len(a) && start < len(b) && Equal(a[start], b[start]) {
+		match[start] = start
+		start++
+	}
+	endA, endB := len(a), len(b)
+	for endA > start && endB > start && Equal(a[endA-1], b[endB-1]) {
+		endA, endB = endA-1, endB-1
+		match[endA] = endB
+	}
+
+	n, m := endA-start, endB-start
+	if n == 0 || m == 0 {
+		return match, true
+	}
+	if n*m > maxMergeListCells {
+		return nil, false
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[start+i:endA] and
+	// b[start+j:endB]
+	lcs := make([][]int32, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if Equal(a[start+i], b[start+j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	for i, j := 0, 0; i < n && j < m; {
+		switch {
+		case Equal(a[start+i], b[start+j]):
+			match[start+i] = start + j
+			i, j = i+1, j+1
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return match, true
+}
@@ -0,0 +1,182 @@
+package nbtreader
+
+import (
+	"fmt"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Strings are stored in Java's Modified UTF-8, as written by DataOutput.writeUTF. It differs from
+// UTF-8 in two ways: the NUL character is encoded in two bytes as C0 80, and supplementary
+// characters, like emoji, are encoded as a surrogate pair of two three byte sequences.
+
+// StrictStrings rejects strings, that are not valid Modified UTF-8, while parsing and strings,
+// that are not valid UTF-8, while composing. Strings of little-endian data, see [ByteOrder], must
+// be valid UTF-8 in both cases. By default, invalid bytes are kept as they are, so
+// they are written back unchanged, and standard UTF-8 four byte sequences are accepted.
+func StrictStrings() Option {
+	return func(o *options) {
+		o.strictStrings = true
+	}
+}
+
+// decodeString decodes the bytes of a string in the encoding of the decoder.
+func (d *decoder) decodeString(b []byte) (string, error) {
+	if !d.utf8 {
+		return decodeMUTF8(b, d.strictStrings)
+	}
+	if d.strictStrings && !utf8.Valid(b) {
+		return "", fmt.Errorf("invalid UTF-8")
+	}
+	return string(b), nil
+}
+
+// appendString appends s to b in the encoding of the encoder.
+func (e *encoder) appendString(b []byte, s String) ([]byte, error) {
+	if !e.utf8 {
+		return appendMUTF8(b, string(s), e.strictStrings)
+	}
+	if e.strictStrings && !utf8.ValidString(string(s)) {
+		return b, fmt.Errorf("invalid UTF-8")
+	}
+	return append(b, s...), nil
+}
+
+// isASCII reports whether b only contains ASCII characters, except NUL, which are encoded the same
+// in UTF-8 and Modified UTF-8.
+func isASCII[S string | []byte](b S) bool {
+	for i := 0; i < len(b); i++ {
+		if b[i] == 0 || b[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeMUTF8 decodes b from Modified UTF-8. In strict mode invalid sequences are an error,
+// otherwise they are kept as they are.
+func decodeMUTF8(b []byte, strict bool) (string, error) {
+	if isASCII(b) {
+		return string(b), nil
+	}
+
+	s := make([]byte, 0, len(b))
+	for i := 0; i < len(b); {
+		c := b[i]
+		switch {
+		case c == 0:
+			if strict {
+				return "", fmt.Errorf("invalid modified UTF-8: NUL byte at %d", i)
+			}
+			s = append(s, c)
+			i++
+			continue
+		case c < utf8.RuneSelf:
+			s = append(s, c)
+			i++
+			continue
+		case c == 0xc0 && i+1 < len(b) && b[i+1] == 0x80:
+			s = append(s, 0)
+			i += 2
+			continue
+		case c >= 0xc2 && c <= 0xdf && i+1 < len(b) && isContinuation(b[i+1]):
+			s = append(s, b[i:i+2]...)
+			i += 2
+			continue
+		case c >= 0xe0 && c <= 0xef && i+2 < len(b) && isContinuation(b[i+1]) && isContinuation(b[i+2]):
+			r := decodeThreeBytes(b[i:])
+			if utf16.IsSurrogate(r) {
+				if r < 0xdc00 && i+5 < len(b) && b[i+3]&0xf0 == 0xe0 && isContinuation(b[i+4]) && isContinuation(b[i+5]) {
+					if r2 := decodeThreeBytes(b[i+3:]); r2 >= 0xdc00 && r2 <= 0xdfff {
+						s = utf8.AppendRune(s, utf16.DecodeRune(r, r2))
+						i += 6
+						continue
+					}
+				}
+			} else if r >= 0x800 {
+				s = append(s, b[i:i+3]...)
+				i += 3
+				continue
+			}
+		case c >= 0xf0 && !strict:
+			// standard UTF-8, as written by other tools than Minecraft
+			if r, size := utf8.DecodeRune(b[i:]); r != utf8.RuneError {
+				s = utf8.AppendRune(s, r)
+				i += size
+				continue
+			}
+		}
+
+		if strict {
+			return "", fmt.Errorf("invalid modified UTF-8 at byte %d", i)
+		}
+		s = append(s, c)
+		i++
+	}
+	return string(s), nil
+}
+
+func isContinuation(c byte) bool {
+	return c&0xc0 == 0x80
+}
+
+// decodeThreeBytes decodes the three byte sequence at the start of b, including surrogates.
+func decodeThreeBytes(b []byte) rune {
+	return rune(b[0]&0x0f)<<12 | rune(b[1]&0x3f)<<6 | rune(b[2]&0x3f)
+}
+
+// appendMUTF8 appends s encoded in Modified UTF-8 to b. In strict mode invalid UTF-8 in s is an
+// error, otherwise the invalid bytes are copied unchanged.
+func appendMUTF8(b []byte, s string, strict bool) ([]byte, error) {
+	if isASCII(s) {
+		return append(b, s...), nil
+	}
+
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			if strict {
+				return b, fmt.Errorf("invalid UTF-8 at byte %d", i)
+			}
+			b = append(b, s[i])
+		case r == 0:
+			b = append(b, 0xc0, 0x80)
+		case r > 0xffff:
+			r1, r2 := utf16.EncodeRune(r)
+			b = appendThreeBytes(appendThreeBytes(b, r1), r2)
+		default:
+			b = append(b, s[i:i+size]...)
+		}
+		i += size
+	}
+	return b, nil
+}
+
+// appendThreeBytes appends r, that may be a surrogate, as three byte sequence.
+func appendThreeBytes(b []byte, r rune) []byte {
+	return append(b, 0xe0|byte(r>>12), 0x80|byte(r>>6)&0x3f, 0x80|byte(r)&0x3f)
+}
+
+// mutf8Len returns the length of s in bytes, when encoded in Modified UTF-8.
+func mutf8Len(s string) int {
+	if isASCII(s) {
+		return len(s)
+	}
+	n := 0
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			n++
+		case r == 0:
+			n += 2
+		case r > 0xffff:
+			n += 6
+		default:
+			n += size
+		}
+		i += size
+	}
+	return n
+}
@@ -3,7 +3,8 @@ package nbtreader
 import (
 	"bufio"
 	"bytes"
-	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -14,31 +15,122 @@ type NBT struct {
 	rw *bufio.ReadWriter
 	w  io.Writer
 
-	rootName String
-	root     NbtTag
+	rootName    String
+	root        NbtTag
+	compression Compression
+	salvaged    *SalvageReport
+	lossless    *lossless
+	// storageVersion is the version in the header of a Bedrock level.dat
+	storageVersion int32
+
+	opts options
+}
+
+// options holds the configuration of an NBT object.
+type options struct {
+	skipValidation bool
+	salvage        bool
+	strict         bool
+	lossless       bool
+	strictStrings  bool
+	limits         Limits
+	level          int
+	hasLevel       bool
+	only           []Path
+	keepSkipped    bool
+	browse         bool
+	order          binary.ByteOrder
+	levelHeader    bool
+}
+
+// Option configures the reading and writing of an NBT object. Options are passed to [New].
+type Option func(*options)
+
+// SkipValidation disables the validation of the tree with [Validate] before it is composed by
+// [NBT.NBT]. Invalid trees may then produce corrupt data.
+func SkipValidation() Option {
+	return func(o *options) {
+		o.skipValidation = true
+	}
+}
+
+// Strict makes [New] fail with [ErrTrailingData], if there is data left after the root tag. By
+// default, the rest of the data is ignored. Use a [Reader] to read data with multiple root tags.
+func Strict() Option {
+	return func(o *options) {
+		o.strict = true
+	}
 }
 
 // New creates a new NBT object. The given data will be completely parsed, including decompression
 // (if compressed).
 //
 // The resulting NBT object can be used to change or get single nbt values and compose it again.
-func New(r io.Reader, w io.Writer) (nbt *NBT, err error) {
-	nbt = &NBT{
-		w: w,
-		rw: bufio.NewReadWriter(
-			bufio.NewReader(r),
-			bufio.NewWriter(w),
-		),
+func New(r io.Reader, w io.Writer, opts ...Option) (nbt *NBT, err error) {
+	nbt = &NBT{w: w}
+	for _, opt := range opts {
+		opt(&nbt.opts)
 	}
-
-	err = nbt.parse()
+	err = nbt.read(r)
 	return nbt, err
 }
 
+// read parses the data of r with the options of nbt.
+func (nbt *NBT) read(r io.Reader) error {
+	if nbt.opts.lossless {
+		nbt.lossless = &lossless{}
+		r = io.TeeReader(r, &nbt.lossless.raw)
+	}
+	nbt.rw = bufio.NewReadWriter(
+		bufio.NewReader(r),
+		bufio.NewWriter(nbt.w),
+	)
+	return nbt.parse()
+}
+
+// FromRoot creates a new NBT object with the given root tag and an empty root name, e.g. for a
+// tree parsed with [ParseSNBT]. Composing it writes the data to w.
+func FromRoot(root NbtTag, w io.Writer, opts ...Option) (*NBT, error) {
+	nbt := &NBT{w: w}
+	for _, opt := range opts {
+		opt(&nbt.opts)
+	}
+	nbt.rw = bufio.NewReadWriter(nil, bufio.NewWriter(w))
+	if err := nbt.SetRoot(root); err != nil {
+		return nil, err
+	}
+	return nbt, nil
+}
+
 // String implements the fmt.Stringer interface. The given NBT object will be converted to a SNBT
 // string, including linebreaks.
 func (nbt NBT) String() string {
-	return fmt.Sprint(nbt.root)
+	if nbt.root == nil {
+		return fmt.Sprint(nil)
+	}
+	return nbt.root.String()
+}
+
+// Root returns the root tag of the NBT object.
+func (nbt NBT) Root() NbtTag {
+	return nbt.root
+}
+
+// RootName returns the name of the root tag. Most files use an empty name.
+func (nbt NBT) RootName() String {
+	return nbt.rootName
+}
+
+// SetRoot replaces the root tag of the NBT object, e.g. with an edited copy of it. Only compounds
+// and lists are valid root tags.
+func (nbt *NBT) SetRoot(root NbtTag) error {
+	switch root.(type) {
+	case Compound, List:
+	default:
+		return fmt.Errorf("nbt: invalid root tag: %s", typeOf(root))
+	}
+	nbt.root = root
+	return nil
 }
 
 func (nbt *NBT) parse() error {
@@ -46,99 +138,175 @@ func (nbt *NBT) parse() error {
 	if err != nil {
 		return fmt.Errorf("nbt: %v", err)
 	}
+	if nbt.opts.levelHeader {
+		if err = nbt.readLevelHeader(); err != nil {
+			return fmt.Errorf("nbt: %v", err)
+		}
+	}
 
-	var rootType TagType
-	rootType, err = popType(nbt.rw)
-	if err != nil {
+	d := newDecoder(nbt.rw, nbt.opts)
+	if nbt.lossless == nil {
+		if err = nbt.parseRoot(d); err != nil {
+			return err
+		}
+		if nbt.opts.strict {
+			return d.checkTrailing(nbt.root.Type())
+		}
+		return nil
+	}
+
+	hash := sha256.New()
+	d.r = io.TeeReader(d.r, hash)
+	if err = nbt.parseRoot(d); err != nil {
 		return err
 	}
+	nbt.lossless.duplicates = d.duplicates
+	if nbt.opts.strict {
+		err = d.checkTrailing(nbt.root.Type())
+	} else if nbt.lossless.trailing, err = io.ReadAll(d); err != nil {
+		err = &ParseError{Offset: d.offset, Type: nbt.root.Type(), Err: err}
+	}
+	hash.Sum(nbt.lossless.sum[:0])
+	if nbt.compression == NONE {
+		// uncompressed data is written again by the encoder, so there is no need to keep it
+		nbt.lossless.raw = bytes.Buffer{}
+	}
+	return err
+}
+
+// newDecoder returns a decoder for r, configured by opts.
+func newDecoder(r io.Reader, opts options) *decoder {
+	d := &decoder{r: r, salvage: opts.salvage, limits: opts.limits, lossless: opts.lossless, strictStrings: opts.strictStrings, only: opts.only, keepSkipped: opts.keepSkipped}
+	d.order, d.utf8 = opts.byteOrder(), opts.utf8()
+	if max := opts.limits.MaxDecompressedSize; max > 0 {
+		d.r = &limitedReader{r: r, n: max, max: max}
+	}
+	return d
+}
+
+// parseRoot parses a single root tag with its name from d.
+func (nbt *NBT) parseRoot(d *decoder) error {
+	rootType, err := popType(d)
+	if err != nil {
+		return fmt.Errorf("nbt: %w", err)
+	}
 
 	switch rootType {
-	case Tag_Compound:
-		nbt.root = Compound{}
-	case Tag_List:
-		nbt.root = List{}
+	case Tag_Compound, Tag_List:
 	default:
-		return fmt.Errorf("nbt: found invalid root tag: %s", rootType)
+		return d.error(rootType, fmt.Errorf("found invalid root tag: %s", rootType))
 	}
 
-	nbt.rootName, err = popString(nbt.rw)
+	nbt.rootName, err = popString(d)
 	if err != nil {
-		return fmt.Errorf("nbt: %v", err)
+		return d.error(rootType, fmt.Errorf("reading root name: %w", noEOF(err)))
 	}
-	nbt.root, err = nbt.root.parse(nbt.rw)
+	root, err := parseType(d, rootType)
 	if err != nil {
+		if d.salvage {
+			return nbt.salvage(d, root, err)
+		}
 		return err
 	}
+	nbt.root = root
+	return nil
+}
 
-	// TODO: check for rest data in reader
-	/* Outdated code
-	if len(nbt.buf) > 0 {
-		return fmt.Errorf("nbt: has %d bytes of rest data after parsing:\n% 02x", len(nbt.buf), nbt.buf)
+// NBT takes the NBT object and composes it to the nbt binary format. If compressed is true, it is
+// compressed the same way as the parsed data, or using gzip if that was not compressed. The data
+// will be written to the underlying [io.Writer].
+//
+// Unless the option [SkipValidation] is set, the tree is checked with [Validate] first and
+// nothing is written if it is invalid.
+func (nbt *NBT) NBT(compressed bool) error {
+	if !compressed {
+		return nbt.Compose(NONE)
+	}
+	switch nbt.compression {
+	case GZIP, ZLIB, LZ4:
+		return nbt.Compose(nbt.compression)
+	default:
+		return nbt.Compose(GZIP)
 	}
-	*/
-	return nil
 }
 
-// NBT takes the NBT object and composes it to the nbt binary format. It also will be compressed
-// using gzip if specified. The data will be written to the underlying [io.Writer].
-func (nbt *NBT) NBT(compressed bool) (err error) {
-	if compressed {
-		underlyingWriter := nbt.rw.Writer
-		gzipWriter := gzip.NewWriter(nbt.rw.Writer)
-		defer func() {
-			if err != nil {
-				return
-			}
-			if err = gzipWriter.Close(); err != nil {
-				return
+// Compose composes the NBT object to the nbt binary format and writes it compressed with c to the
+// underlying [io.Writer]. Use [NBT.Compression] to write it the same way as the parsed data. The
+// level of gzip and zlib compression is set with the option [CompressionLevel].
+//
+// Unless the option [SkipValidation] is set, the tree is checked with [Validate] first and
+// nothing is written if it is invalid.
+func (nbt *NBT) Compose(c Compression) error {
+	if !nbt.opts.skipValidation {
+		if err := Validate(nbt.root); err != nil {
+			return fmt.Errorf("nbt: invalid tree: %w", err)
+		}
+		if n := mutf8Len(string(nbt.rootName)); n > MaxStringLength {
+			return fmt.Errorf("nbt: root name length %d exceeds maximum of %d bytes", n, MaxStringLength)
+		}
+	}
+
+	if c != NONE && c == nbt.compression && nbt.lossless != nil {
+		var buf bytes.Buffer
+		if err := nbt.compose(&buf); err != nil {
+			return err
+		}
+		if sha256.Sum256(buf.Bytes()) == nbt.lossless.sum {
+			// the data is unchanged, so the original compressed data is written, as the output of
+			// the compressor may differ
+			if _, err := nbt.rw.Write(nbt.lossless.raw.Bytes()); err != nil {
+				return err
 			}
-			nbt.rw.Writer = underlyingWriter
-			err = nbt.rw.Flush()
-		}()
-		nbt.rw.Writer = bufio.NewWriter(gzipWriter)
+			return nbt.rw.Flush()
+		}
 	}
 
-	if err = pushByte(nbt.rw, nbt.root.Type()); err != nil {
+	cw, err := nbt.compressor(nbt.rw, c)
+	if err != nil {
+		return fmt.Errorf("nbt: %v", err)
+	}
+	w := bufio.NewWriter(cw)
+	if nbt.opts.levelHeader {
+		err = nbt.composeLevel(w)
+	} else {
+		err = nbt.compose(w)
+	}
+	if err != nil {
 		return err
 	}
-	if err = pushString(nbt.rw, nbt.rootName); err != nil {
+	if err = w.Flush(); err != nil {
 		return err
 	}
-
-	if err = nbt.root.compose(nbt.rw); err != nil {
+	if err = cw.Close(); err != nil {
 		return err
 	}
 	return nbt.rw.Flush()
 }
 
-type compression = byte
-
-const (
-	NONE compression = iota
-	GZIP
-	ZIP
-	TAR
-)
-
-func (nbt *NBT) decompress() error {
-	switch c := nbt.getCompressionType(); c {
-	case NONE:
-		return nil
-	case GZIP:
-		gzipReader, err := gzip.NewReader(nbt.rw.Reader)
-		if err != nil {
-			return err
-		}
-		nbt.rw.Reader = bufio.NewReader(gzipReader)
-		return nil
-	case ZIP:
-		return fmt.Errorf("file has ZIP compression: ZIP is not supportet yet")
-	case TAR:
-		return fmt.Errorf("file has TAR compression: TAR is not supportet yet")
-	default:
-		return fmt.Errorf("file has unsupported compression: %2x", c)
+// compose writes the root tag with its name to w.
+func (nbt *NBT) compose(w io.Writer) error {
+	e := newEncoder(w, nbt.opts)
+	e.lossless = nbt.lossless
+	if err := pushByte(e, nbt.root.Type()); err != nil {
+		return err
+	}
+	if err := pushString(e, nbt.rootName); err != nil {
+		return err
 	}
+	if err := nbt.root.compose(e); err != nil {
+		return err
+	}
+	if nbt.lossless != nil {
+		_, err := e.Write(nbt.lossless.trailing)
+		return err
+	}
+	return nil
+}
+
+// Compressed reports whether the parsed data was compressed, so it can be written back the same
+// way.
+func (nbt NBT) Compressed() bool {
+	return nbt.compression != NONE
 }
 
 func (nbt *NBT) MarshalJSON() ([]byte, error) {
@@ -148,21 +316,3 @@ func (nbt *NBT) MarshalJSON() ([]byte, error) {
 func (nbt *NBT) MarshalNJSON() ([]byte, error) {
 	return MarshalNJSON(nbt.root)
 }
-
-func (nbt NBT) getCompressionType() compression {
-	buf, err := nbt.rw.Peek(4)
-	if err != nil {
-		panic(err)
-	}
-
-	switch {
-	case bytes.Equal(buf[:3], []byte{0x1f, 0x8b, 0x08}):
-		return GZIP
-	case bytes.Equal(buf[:4], []byte{0x50, 0x4b, 0x03, 0x04}):
-		return ZIP
-	case bytes.Equal(buf[:4], []byte{0x75, 0x73, 0x74, 0x61}):
-		return TAR
-	default:
-		return NONE
-	}
-}
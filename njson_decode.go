@@ -0,0 +1,294 @@
+package nbtreader
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ParseJSON parses plain JSON, like the output of json.Marshal on a tag, and returns the resulting
+// tag. As JSON has no types for numbers, integers become Int, or Long if they don't fit, and all
+// other numbers become Double. Booleans become Byte. The elements of an array form a list, so
+// they must have the same type, except for numbers, which are converted to the widest type.
+func ParseJSON(data []byte) (NbtTag, error) {
+	return parseJSON(data, false)
+}
+
+// ParseNJSON parses NJSON, like the output of [MarshalNJSON], and returns the resulting tag. The
+// types are taken from the type annotations of keys and lists, values without one are parsed
+// like in [ParseJSON].
+func ParseNJSON(data []byte) (NbtTag, error) {
+	return parseJSON(data, true)
+}
+
+func parseJSON(data []byte, njson bool) (NbtTag, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	p := &jsonParser{dec: dec, njson: njson}
+	tag, err := p.parseValue(NoAnnotation)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = dec.Token(); err != io.EOF {
+		return nil, p.errorf("unexpected trailing data")
+	}
+	return tag, nil
+}
+
+type jsonParser struct {
+	dec   *json.Decoder
+	njson bool
+}
+
+func (p *jsonParser) errorf(format string, a ...any) error {
+	prefix := "json"
+	if p.njson {
+		prefix = "njson"
+	}
+	return fmt.Errorf("%s: %s at offset %d", prefix, fmt.Sprintf(format, a...), p.dec.InputOffset())
+}
+
+// parseValue parses the next value with the type of the annotation anno. Without an annotation,
+// the type is inferred from the value.
+func (p *jsonParser) parseValue(anno TypeAnnotation) (NbtTag, error) {
+	token, err := p.dec.Token()
+	if err != nil {
+		return nil, p.errorf("%v", noEOF(err))
+	}
+
+	switch t := token.(type) {
+	case json.Delim:
+		switch {
+		case t == '{' && (anno == NoAnnotation || anno == CompoundAnnotation):
+			return p.parseObject()
+		case t == '[' && anno == NoAnnotation:
+			return p.parseList()
+		case t == '[' && (anno == ByteArrayAnnotation || anno == IntArrayAnnotation || anno == LongArrayAnnotation):
+			return p.parseArray(anno)
+		}
+	case json.Number:
+		return p.parseNumber(t, anno)
+	case string:
+		if anno == NoAnnotation || anno == StringAnnotation {
+			return String(t), nil
+		}
+	case bool:
+		if anno == NoAnnotation || anno == ByteAnnotation {
+			if t {
+				return Byte(1), nil
+			}
+			return Byte(0), nil
+		}
+	case nil:
+		return nil, p.errorf("null is not supported")
+	}
+	return nil, p.errorf("unexpected %v for type annotation %s", token, anno)
+}
+
+func (p *jsonParser) parseObject() (NbtTag, error) {
+	c := Compound{}
+	for p.dec.More() {
+		token, err := p.dec.Token()
+		if err != nil {
+			return nil, p.errorf("%v", noEOF(err))
+		}
+		key := token.(string)
+		anno := NoAnnotation
+		if p.njson {
+			key, anno = splitAnnotation(key)
+		}
+		value, err := p.parseValue(anno)
+		if err != nil {
+			return nil, err
+		}
+		c.Set(String(key), value)
+	}
+	// the closing brace
+	if _, err := p.dec.Token(); err != nil {
+		return nil, p.errorf("%v", noEOF(err))
+	}
+	return c, nil
+}
+
+func (p *jsonParser) parseList() (NbtTag, error) {
+	l := List{TagType: Tag_End}
+	elementAnno := NoAnnotation
+	for p.dec.More() {
+		value, err := p.parseValue(elementAnno)
+		if err != nil {
+			return nil, err
+		}
+		if s, ok := value.(String); ok && p.njson && len(l.Elements) == 0 && elementAnno == NoAnnotation {
+			// the first element of an NJSON list may be the type annotation of its elements
+			if _, anno := splitAnnotation(string(s)); anno != NoAnnotation && strings.HasPrefix(string(s), "<") {
+				elementAnno = anno
+				l.TagType = annotationType(anno)
+				continue
+			}
+		}
+		l.Elements = append(l.Elements, value)
+	}
+	if _, err := p.dec.Token(); err != nil {
+		return nil, p.errorf("%v", noEOF(err))
+	}
+	if elementAnno != NoAnnotation || len(l.Elements) == 0 {
+		return l, nil
+	}
+
+	l.TagType = l.Elements[0].Type()
+	for _, e := range l.Elements[1:] {
+		if e.Type() != l.TagType {
+			return unifyNumbers(l)
+		}
+	}
+	return l, nil
+}
+
+func (p *jsonParser) parseArray(anno TypeAnnotation) (NbtTag, error) {
+	var (
+		elementAnno TypeAnnotation
+		array       NbtTag
+	)
+	switch anno {
+	case ByteArrayAnnotation:
+		elementAnno, array = ByteAnnotation, ByteArray{}
+	case IntArrayAnnotation:
+		elementAnno, array = IntAnnotation, IntArray{}
+	default:
+		elementAnno, array = LongAnnotation, LongArray{}
+	}
+	for p.dec.More() {
+		value, err := p.parseValue(elementAnno)
+		if err != nil {
+			return nil, err
+		}
+		switch a := array.(type) {
+		case ByteArray:
+			array = append(a, value.(Byte))
+		case IntArray:
+			array = append(a, value.(Int))
+		case LongArray:
+			array = append(a, value.(Long))
+		}
+	}
+	if _, err := p.dec.Token(); err != nil {
+		return nil, p.errorf("%v", noEOF(err))
+	}
+	return array, nil
+}
+
+// parseNumber parses n to the number type of the annotation anno. Without an annotation it is an
+// Int, Long or Double, depending on its value.
+func (p *jsonParser) parseNumber(n json.Number, anno TypeAnnotation) (NbtTag, error) {
+	s := n.String()
+	switch anno {
+	case NoAnnotation:
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			if i >= math.MinInt32 && i <= math.MaxInt32 {
+				return Int(i), nil
+			}
+			return Long(i), nil
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %s", s)
+		}
+		return Double(f), nil
+	case FloatAnnotation, DoubleAnnotation:
+		bitSize := 64
+		if anno == FloatAnnotation {
+			bitSize = 32
+		}
+		f, err := strconv.ParseFloat(s, bitSize)
+		if err != nil {
+			return nil, p.errorf("invalid %s %s", annotationType(anno), s)
+		}
+		if anno == FloatAnnotation {
+			return Float(f), nil
+		}
+		return Double(f), nil
+	}
+
+	bitSize := map[TypeAnnotation]int{ByteAnnotation: 8, ShortAnnotation: 16, IntAnnotation: 32, LongAnnotation: 64}[anno]
+	if bitSize == 0 {
+		return nil, p.errorf("unexpected number %s for type annotation %s", s, anno)
+	}
+	i, err := strconv.ParseInt(s, 10, bitSize)
+	if err != nil {
+		return nil, p.errorf("invalid %s %s", annotationType(anno), s)
+	}
+	switch anno {
+	case ByteAnnotation:
+		return Byte(i), nil
+	case ShortAnnotation:
+		return Short(i), nil
+	case IntAnnotation:
+		return Int(i), nil
+	default:
+		return Long(i), nil
+	}
+}
+
+// unifyNumbers converts the elements of a list of mixed Int, Long and Double to the widest of
+// them. Other mixed lists are an error.
+func unifyNumbers(l List) (NbtTag, error) {
+	for _, e := range l.Elements {
+		switch e.Type() {
+		case Tag_Int:
+		case Tag_Long:
+			if l.TagType == Tag_Int {
+				l.TagType = Tag_Long
+			}
+		case Tag_Double:
+			l.TagType = Tag_Double
+		default:
+			return nil, fmt.Errorf("json: list has elements of type %s and %s", l.TagType, e.Type())
+		}
+	}
+	for i, e := range l.Elements {
+		switch v := e.(type) {
+		case Int:
+			l.Elements[i] = convertNumber(int64(v), l.TagType)
+		case Long:
+			l.Elements[i] = convertNumber(int64(v), l.TagType)
+		}
+	}
+	return l, nil
+}
+
+func convertNumber(i int64, t TagType) NbtTag {
+	if t == Tag_Double {
+		return Double(i)
+	}
+	return Long(i)
+}
+
+// splitAnnotation splits an NJSON key into the name and its type annotation, like "name<b>". Keys
+// without a valid annotation are returned unchanged with NoAnnotation.
+func splitAnnotation(key string) (string, TypeAnnotation) {
+	i := strings.LastIndexByte(key, '<')
+	if i < 0 || !strings.HasSuffix(key, ">") {
+		return key, NoAnnotation
+	}
+	chars, _, _ := strings.Cut(key[i+1:len(key)-1], ":")
+	for anno := CompoundAnnotation; anno <= StringAnnotation; anno++ {
+		if anno != InferenceArrayAnnotation && anno.Characters() == chars {
+			return key[:i], anno
+		}
+	}
+	return key, NoAnnotation
+}
+
+// annotationType returns the tag type of the type annotation anno.
+func annotationType(anno TypeAnnotation) TagType {
+	for t := Tag_Byte; t <= Tag_Long_Array; t++ {
+		if t != Tag_List && t.Annotation() == anno {
+			return t
+		}
+	}
+	return Tag_End
+}
@@ -104,6 +104,15 @@ func (e *njsonEncoderState) valueEncoder(v reflect.Value) {
 	case reflect.Slice:
 		e.arrayEncoder(v)
 	case reflect.Map:
+		if m, ok := v.Interface().(Marshaler); ok {
+			// compounds in lists keep their order and type annotations
+			b, err := m.MarshalNJSON()
+			if err != nil {
+				e.error(err)
+			}
+			e.Write(b)
+			return
+		}
 		e.objectEncoder(v)
 
 	case reflect.Pointer, reflect.Interface:
@@ -2,12 +2,200 @@ package nbtreader
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"math"
+	"slices"
+	"unsafe"
 )
 
-func parseType(r io.Reader, tagType TagType) (NbtTag, error) {
+// ParseError describes a failure while parsing NBT data. It can be retrieved from the errors
+// returned by [New] with errors.As.
+type ParseError struct {
+	// Offset is the position in the decompressed data, where the parsing failed.
+	Offset int64
+	// Path is the path of the tag, that was parsed.
+	Path Path
+	// Type is the expected type of the tag at Path.
+	Type TagType
+	// Err is the underlying error.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *ParseError) Error() string {
+	path := e.Path.String()
+	if path == "" {
+		path = "root"
+	}
+	return fmt.Sprintf("nbt: parse error at offset %d in %s (%s): %v", e.Offset, path, e.Type, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ErrTrailingData is returned, wrapped in a [*ParseError], by [New] with the option [Strict], if
+// there is data left after the root tag.
+var ErrTrailingData = errors.New("trailing data")
+
+// decoder reads NBT data and keeps track of the position in the data and the path of the tag,
+// that is currently parsed.
+type decoder struct {
+	r      io.Reader
+	offset int64
+	path   Path
+
+	// salvage keeps partially parsed compounds and lists on errors and records them in
+	// truncations
+	salvage     bool
+	truncations []Truncation
+
+	// limits restricts the resources used, size is the accounted size of the tree so far
+	limits Limits
+	size   int64
+
+	// strictStrings rejects strings, that are not valid Modified UTF-8
+	strictStrings bool
+
+	// order is the byte order of numbers and lengths, utf8 reads strings as standard UTF-8
+	// instead of Modified UTF-8, both for Bedrock's NBT
+	order binary.ByteOrder
+	utf8  bool
+
+	// lossless records overwritten compound entries in duplicates
+	lossless   bool
+	duplicates map[unsafe.Pointer][]duplicate
+
+	// only holds the paths to decode, all other tags are skipped or kept as RawTag with
+	// keepSkipped
+	only        []Path
+	keepSkipped bool
+
+	// buf is reused for reading numbers, strings and arrays, keys holds the keys read so far to
+	// share them between compounds
+	buf  []byte
+	keys map[string]String
+}
+
+func (d *decoder) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	d.offset += int64(n)
+	return n, err
+}
+
+// readN reads the next n bytes into the buffer of the decoder. They are valid until the next call.
+func (d *decoder) readN(n int) ([]byte, error) {
+	if cap(d.buf) < n {
+		d.buf = make([]byte, n)
+	}
+	b := d.buf[:n]
+	_, err := io.ReadFull(d, b)
+	return b, err
+}
+
+// readN reads the next n bytes from r, using the buffer of r if it is a decoder.
+func readN(r io.Reader, n int) ([]byte, error) {
+	if d, ok := r.(*decoder); ok {
+		return d.readN(n)
+	}
+	b := make([]byte, n)
+	_, err := io.ReadFull(r, b)
+	return b, err
+}
+
+// arrayChunk is the number of bytes of an array, that are read or written at once.
+const arrayChunk = 1 << 15
+
+// readArray reads n elements of size bytes each in chunks and passes the data of each chunk to
+// add. The chunks keep the memory bounded, if the length is corrupt.
+func (d *decoder) readArray(n, size int, add func(b []byte)) error {
+	for n > 0 {
+		k := min(n, arrayChunk/size)
+		b, err := d.readN(k * size)
+		if err != nil {
+			return err
+		}
+		add(b)
+		n -= k
+	}
+	return nil
+}
+
+// error returns a *ParseError for the current position, with err as underlying error. An io.EOF
+// is turned into an io.ErrUnexpectedEOF, because it happens in the middle of a tag. Errors, that
+// already are a *ParseError, are returned as is.
+func (d *decoder) error(tagType TagType, err error) error {
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		return err
+	}
+	return &ParseError{Offset: d.offset, Path: slices.Clone(d.path), Type: tagType, Err: noEOF(err)}
+}
+
+// truncated records, that the compound or list at the current path was closed early with parsed
+// of expected entries. It does nothing when not salvaging.
+func (d *decoder) truncated(tagType TagType, parsed, expected int) {
+	if !d.salvage {
+		return
+	}
+	d.truncations = append(d.truncations, Truncation{
+		Path:     slices.Clone(d.path),
+		Type:     tagType,
+		Parsed:   parsed,
+		Expected: expected,
+	})
+}
+
+// isContainer reports whether tag is a compound or a list, that can be kept partially parsed.
+func isContainer(tag NbtTag) bool {
+	switch tag.(type) {
+	case Compound, List:
+		return true
+	default:
+		return false
+	}
+}
+
+// checkTrailing returns an error with ErrTrailingData, if there is any data left in d after the
+// root tag of type rootType.
+func (d *decoder) checkTrailing(rootType TagType) error {
+	offset := d.offset
+	n, err := io.Copy(io.Discard, d)
+	if err != nil {
+		return &ParseError{Offset: d.offset, Type: rootType, Err: err}
+	}
+	if n > 0 {
+		return &ParseError{Offset: offset, Type: rootType, Err: fmt.Errorf("%w: %d bytes after the root tag", ErrTrailingData, n)}
+	}
+	return nil
+}
+
+// noEOF turns an io.EOF into an io.ErrUnexpectedEOF, for reads in the middle of a tag.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func parseType(d *decoder, tagType TagType) (NbtTag, error) {
+	if d.only != nil && !d.wanted() {
+		if d.keepSkipped {
+			tag, err := RawTag{tagType: tagType}.parse(d)
+			if err != nil {
+				return nil, d.error(tagType, err)
+			}
+			return tag, nil
+		}
+		// a skipped tag is left out of its compound or list
+		if err := d.skipTag(tagType, nil); err != nil {
+			return nil, d.error(tagType, err)
+		}
+		return nil, nil
+	}
+
 	var tag NbtTag
 	switch tagType {
 	case Tag_Byte:
@@ -35,88 +223,135 @@ func parseType(r io.Reader, tagType TagType) (NbtTag, error) {
 	case Tag_Long_Array:
 		tag = LongArray{}
 	default:
-		return nil, fmt.Errorf("unknown type 0x%02x", byte(tagType))
+		return nil, d.error(tagType, fmt.Errorf("unknown type 0x%02x", byte(tagType)))
+	}
+	if tagType == Tag_List || tagType == Tag_Compound {
+		if err := d.checkDepth(); err != nil {
+			return nil, d.error(tagType, err)
+		}
+	}
+	if err := d.account(tagSize(tagType)); err != nil {
+		return nil, d.error(tagType, err)
 	}
-	return tag.parse(r)
+	tag, err := tag.parse(d)
+	if err != nil {
+		return tag, d.error(tagType, err)
+	}
+	return tag, nil
 }
 
-func (t Byte) parse(r io.Reader) (NbtTag, error) {
-	return popByte(r)
+func (t Byte) parse(d *decoder) (NbtTag, error) {
+	return popByte(d)
 }
 
-func (t Short) parse(r io.Reader) (NbtTag, error) {
-	return popShort(r)
+func (t Short) parse(d *decoder) (NbtTag, error) {
+	return popShort(d)
 }
 
-func (t Int) parse(r io.Reader) (NbtTag, error) {
-	return popInt(r)
+func (t Int) parse(d *decoder) (NbtTag, error) {
+	return popInt(d)
 }
 
-func (t Long) parse(r io.Reader) (NbtTag, error) {
-	return popLong(r)
+func (t Long) parse(d *decoder) (NbtTag, error) {
+	return popLong(d)
 }
 
-func (t Float) parse(r io.Reader) (NbtTag, error) {
-	return popFloat(r)
+func (t Float) parse(d *decoder) (NbtTag, error) {
+	return popFloat(d)
 }
 
-func (t Double) parse(r io.Reader) (NbtTag, error) {
-	return popDouble(r)
+func (t Double) parse(d *decoder) (NbtTag, error) {
+	return popDouble(d)
 }
 
-func (t ByteArray) parse(r io.Reader) (NbtTag, error) {
-	itemCap, err := popInt(r)
+func (t ByteArray) parse(d *decoder) (NbtTag, error) {
+	itemCap, err := popInt(d)
 	if err != nil {
 		return t, err
 	}
+	if err = d.checkLength(itemCap); err != nil {
+		return t, err
+	}
+	if err = d.account(int64(itemCap)); err != nil {
+		return t, err
+	}
 
-	t = make([]Byte, itemCap)
-	for i, item := range t {
-		item, err = popByte(r)
-		if err != nil {
-			return t, err
+	t = make([]Byte, 0, min(itemCap, maxPrealloc))
+	err = d.readArray(int(itemCap), 1, func(b []byte) {
+		n := len(t)
+		t = slices.Grow(t, len(b))[:n+len(b)]
+		for i, v := range b {
+			t[n+i] = Byte(v)
 		}
-		t[i] = item
-	}
-	return t, nil
+	})
+	return t, err
 }
 
-func (t String) parse(r io.Reader) (NbtTag, error) {
-	return popString(r)
+func (t String) parse(d *decoder) (NbtTag, error) {
+	t, err := popString(d)
+	if err != nil {
+		return t, err
+	}
+	return t, d.account(2 * int64(len(t)))
 }
 
-func (t List) parse(r io.Reader) (NbtTag, error) {
-	i, err := popByte(r)
+func (t List) parse(d *decoder) (NbtTag, error) {
+	i, err := popByte(d)
 	if err != nil {
+		d.truncated(Tag_List, 0, -1)
 		return t, err
 	}
 	t.TagType = TagType(i)
-	itemCap, err := popInt(r)
+	itemCap, err := popInt(d)
 	if err != nil {
+		d.truncated(Tag_List, 0, -1)
+		return t, err
+	}
+	if err = d.checkLength(itemCap); err != nil {
+		d.truncated(Tag_List, 0, -1)
 		return t, err
 	}
 	if t.TagType == Tag_End && itemCap > 0 {
+		d.truncated(Tag_List, 0, int(itemCap))
 		return t, fmt.Errorf("list cannot be of type TAG_END")
 	}
+	if err = d.account(4 * int64(itemCap)); err != nil {
+		d.truncated(Tag_List, 0, int(itemCap))
+		return t, err
+	}
 
-	t.Elements = make([]NbtTag, itemCap)
-	for i, entry := range t.Elements {
-		entry, err = parseType(r, t.TagType)
+	t.Elements = make([]NbtTag, 0, min(itemCap, maxPrealloc))
+	for i := 0; i < int(itemCap); i++ {
+		d.path = append(d.path, PathElement{Index: i, IsIndex: true})
+		entry, err := parseType(d, t.TagType)
+		d.path = d.path[:len(d.path)-1]
 		if err != nil {
+			if d.salvage && isContainer(entry) {
+				t.Elements = append(t.Elements, entry)
+			}
+			d.truncated(Tag_List, len(t.Elements), int(itemCap))
 			return t, err
 		}
-		t.Elements[i] = entry
+		if entry == nil {
+			continue
+		}
+		t.Elements = append(t.Elements, entry)
 	}
 	return t, nil
 }
 
-func (t Compound) parse(r io.Reader) (NbtTag, error) {
-	index := 0
-	for {
+func (t Compound) parse(d *decoder) (NbtTag, error) {
+	// positions holds the position of the last entry of each key, to record duplicates
+	var positions map[String]int
+	if d.lossless {
+		positions = map[String]int{}
+	}
+	for pos := 0; ; pos++ {
 		var i Byte
 		var err error
-		i, err = popByte(r)
+		i, err = popByte(d)
 		if err != nil {
+			d.truncated(Tag_Compound, len(t), -1)
 			return t, err
 		}
 		tagType := TagType(i)
@@ -127,75 +362,117 @@ func (t Compound) parse(r io.Reader) (NbtTag, error) {
 
 		var key String
 		var child NbtTag
-		key, err = popString(r)
-		child, err = parseType(r, tagType)
+		key, err = popKey(d)
+		if err != nil {
+			d.truncated(Tag_Compound, len(t), -1)
+			return t, fmt.Errorf("reading key: %w", noEOF(err))
+		}
+		if err = d.account(sizeCompoundEntry + sizeString + 2*int64(len(key))); err != nil {
+			d.truncated(Tag_Compound, len(t), -1)
+			return t, err
+		}
+		d.path = append(d.path, PathElement{Key: key})
+		child, err = parseType(d, tagType)
+		d.path = d.path[:len(d.path)-1]
 		if err != nil {
+			if d.salvage && isContainer(child) {
+				t.Set(key, child)
+			}
+			d.truncated(Tag_Compound, len(t), -1)
 			return t, err
 		}
-		t[key] = struct {
-			Index int
-			Value NbtTag
-		}{Index: index, Value: child}
-		index++
+		if child == nil {
+			continue
+		}
+		if old, ok := t[key]; ok && d.lossless {
+			// keep the overwritten entry and move the key to its last position
+			d.addDuplicate(t, duplicate{pos: positions[key], key: key, value: old.Value})
+			t.Delete(key)
+		}
+		if positions != nil {
+			positions[key] = pos
+		}
+		// without lossless mode, a duplicate key keeps its first position, but gets the last value
+		t.Set(key, child)
 	}
 }
 
-func (t IntArray) parse(r io.Reader) (NbtTag, error) {
-	itemCap, err := popInt(r)
+func (t IntArray) parse(d *decoder) (NbtTag, error) {
+	itemCap, err := popInt(d)
 	if err != nil {
 		return t, err
 	}
+	if err = d.checkLength(itemCap); err != nil {
+		return t, err
+	}
+	if err = d.account(4 * int64(itemCap)); err != nil {
+		return t, err
+	}
 
-	t = make([]Int, itemCap)
-	for i, item := range t {
-		item, err = popInt(r)
-		if err != nil {
-			return t, err
+	t = make([]Int, 0, min(itemCap, maxPrealloc))
+	err = d.readArray(int(itemCap), 4, func(b []byte) {
+		n := len(t)
+		t = slices.Grow(t, len(b)/4)[:n+len(b)/4]
+		for i := range t[n:] {
+			t[n+i] = Int(d.order.Uint32(b[4*i:]))
 		}
-		t[i] = item
-	}
-	return t, nil
+	})
+	return t, err
 }
 
-func (t LongArray) parse(r io.Reader) (NbtTag, error) {
-	itemCap, err := popInt(r)
+func (t LongArray) parse(d *decoder) (NbtTag, error) {
+	itemCap, err := popInt(d)
 	if err != nil {
 		return t, err
 	}
+	if err = d.checkLength(itemCap); err != nil {
+		return t, err
+	}
+	if err = d.account(8 * int64(itemCap)); err != nil {
+		return t, err
+	}
 
-	t = make([]Long, itemCap)
-	for i, item := range t {
-		item, err = popLong(r)
-		if err != nil {
-			return t, err
+	t = make([]Long, 0, min(itemCap, maxPrealloc))
+	err = d.readArray(int(itemCap), 8, func(b []byte) {
+		n := len(t)
+		t = slices.Grow(t, len(b)/8)[:n+len(b)/8]
+		for i := range t[n:] {
+			t[n+i] = Long(d.order.Uint64(b[8*i:]))
 		}
-		t[i] = item
-	}
-	return t, nil
+	})
+	return t, err
 }
 
 func popByte(r io.Reader) (Byte, error) {
-	var buf [1]byte
-	_, err := io.ReadFull(r, buf[:])
-	return Byte(buf[0]), err
+	b, err := readN(r, 1)
+	if err != nil {
+		return 0, err
+	}
+	return Byte(b[0]), nil
 }
 
 func popShort(r io.Reader) (Short, error) {
-	var buf [2]byte
-	_, err := io.ReadFull(r, buf[:])
-	return Short(binary.BigEndian.Uint16(buf[:])), err
+	b, err := readN(r, 2)
+	if err != nil {
+		return 0, err
+	}
+	return Short(byteOrder(r).Uint16(b)), nil
 }
 
 func popInt(r io.Reader) (Int, error) {
-	var buf [4]byte
-	_, err := io.ReadFull(r, buf[:])
-	return Int(binary.BigEndian.Uint32(buf[:])), err
+	b, err := readN(r, 4)
+	if err != nil {
+		return 0, err
+	}
+	return Int(byteOrder(r).Uint32(b)), nil
 }
 
 func popLong(r io.Reader) (Long, error) {
-	var buf [8]byte
-	_, err := io.ReadFull(r, buf[:])
-	return Long(binary.BigEndian.Uint64(buf[:])), err
+	b, err := readN(r, 8)
+	if err != nil {
+		return 0, err
+	}
+	return Long(byteOrder(r).Uint64(b)), nil
 }
 
 func popFloat(r io.Reader) (Float, error) {
@@ -210,13 +487,50 @@ func popDouble(r io.Reader) (Double, error) {
 	return d, err
 }
 
-func popString(r io.Reader) (String, error) {
-	lenName, err := popShort(r)
+func popString(d *decoder) (String, error) {
+	lenName, err := popShort(d)
 	if err != nil {
 		return "", err
 	}
 
-	p := make([]byte, lenName)
-	_, err = io.ReadFull(r, p)
-	return String(p), err
+	// the length is unsigned, so strings up to 65535 bytes are valid
+	p, err := d.readN(int(uint16(lenName)))
+	if err != nil {
+		return "", err
+	}
+	s, err := d.decodeString(p)
+	return String(s), err
+}
+
+// maxKeys and maxKeyLength limit the keys, that are shared by popKey.
+const (
+	maxKeys      = 1 << 12
+	maxKeyLength = 64
+)
+
+// popKey reads a compound key like popString. Keys repeat a lot, e.g. in lists of compounds, so
+// they are read once and shared afterwards.
+func popKey(d *decoder) (String, error) {
+	lenName, err := popShort(d)
+	if err != nil {
+		return "", err
+	}
+	p, err := d.readN(int(uint16(lenName)))
+	if err != nil {
+		return "", err
+	}
+	if key, ok := d.keys[string(p)]; ok {
+		return key, nil
+	}
+	s, err := d.decodeString(p)
+	if err != nil {
+		return "", err
+	}
+	if len(p) <= maxKeyLength && len(d.keys) < maxKeys {
+		if d.keys == nil {
+			d.keys = make(map[string]String)
+		}
+		d.keys[string(p)] = String(s)
+	}
+	return String(s), nil
 }
@@ -0,0 +1,261 @@
+package nbtreader
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+// PatchOp is the kind of an [Operation] in a [Patch].
+type PatchOp string
+
+const (
+	// PatchAdd adds Value at Path. For compounds an existing key is replaced, for lists and arrays
+	// the value is inserted at the index, where the length of the list appends to it.
+	PatchAdd PatchOp = "add"
+	// PatchRemove removes the tag at Path.
+	PatchRemove PatchOp = "remove"
+	// PatchReplace replaces the existing tag at Path with Value.
+	PatchReplace PatchOp = "replace"
+	// PatchMove removes the tag at From and adds it at Path. Moving a compound key to itself moves
+	// it to the end of the compound.
+	PatchMove PatchOp = "move"
+	// PatchTest checks that the tag at Path is equal to Value and fails the patch otherwise.
+	PatchTest PatchOp = "test"
+)
+
+// Operation is a single step of a [Patch].
+type Operation struct {
+	Op    PatchOp
+	Path  Path
+	From  Path
+	Value NbtTag
+}
+
+// Patch is a list of operations to change a tree of tags, similar to a JSON Patch (RFC 6902) with
+// NBT paths and typed values. Encoded as JSON, the values are stored as SNBT strings:
+//
+//	[
+//		{"op": "test", "path": "Data.LevelName", "value": "\"My World\""},
+//		{"op": "replace", "path": "Data.LevelName", "value": "\"My new World\""},
+//		{"op": "add", "path": "Data.Player.Inventory[0]", "value": "{Count:1b,id:\"minecraft:stone\"}"},
+//		{"op": "move", "from": "Data.Player.Motion", "path": "Data.Player.Velocity"}
+//	]
+type Patch []Operation
+
+func (o Operation) String() string {
+	if o.Op == PatchMove {
+		return fmt.Sprintf("%s %s to %s", o.Op, o.From, o.Path)
+	}
+	return fmt.Sprintf("%s %s", o.Op, o.Path)
+}
+
+type jsonOperation struct {
+	Op    PatchOp `json:"op"`
+	From  *Path   `json:"from,omitempty"`
+	Path  Path    `json:"path"`
+	Value *string `json:"value,omitempty"`
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (o Operation) MarshalJSON() ([]byte, error) {
+	v := jsonOperation{Op: o.Op, Path: o.Path}
+	if o.Op == PatchMove {
+		v.From = &o.From
+	}
+	if o.Value != nil {
+		b, err := MarshalSNBT(o.Value)
+		if err != nil {
+			return nil, err
+		}
+		value := string(b)
+		v.Value = &value
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (o *Operation) UnmarshalJSON(data []byte) error {
+	var v jsonOperation
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*o = Operation{Op: v.Op, Path: v.Path}
+	if v.From != nil {
+		o.From = *v.From
+	}
+	if v.Value != nil {
+		value, err := ParseSNBT([]byte(*v.Value))
+		if err != nil {
+			return fmt.Errorf("patch: value of %s: %v", o, err)
+		}
+		o.Value = value
+	}
+	return nil
+}
+
+// ApplyPatch applies all operations of patch to a copy of tree and returns the result. If any
+// operation fails, the error is returned and tree stays untouched.
+func ApplyPatch(tree NbtTag, patch Patch) (NbtTag, error) {
+	return applyPatch(Clone(tree), patch)
+}
+
+// applyPatch applies patch to tree. The edited compounds, lists and arrays are copied, so tree is
+// not modified and shares the unchanged tags with the result.
+func applyPatch(tree NbtTag, patch Patch) (NbtTag, error) {
+	for i, op := range patch {
+		var err error
+		if tree, err = op.apply(tree); err != nil {
+			return nil, fmt.Errorf("patch: operation %d (%s): %v", i, op, err)
+		}
+	}
+	return tree, nil
+}
+
+func (o Operation) apply(tree NbtTag) (NbtTag, error) {
+	switch o.Op {
+	case PatchAdd:
+		return addPath(tree, o.Path, o.Value)
+	case PatchRemove:
+		tree, _, err := removePath(tree, o.Path)
+		return tree, err
+	case PatchReplace:
+		if o.Value == nil {
+			return nil, fmt.Errorf("missing value")
+		}
+		return updatePath(tree, o.Path, func(old NbtTag) (NbtTag, error) {
+			return o.Value, nil
+		})
+	case PatchMove:
+		if len(o.From) < len(o.Path) && slices.Equal(o.From, o.Path[:len(o.From)]) {
+			return nil, fmt.Errorf("cannot move %s into itself", o.From)
+		}
+		tree, value, err := removePath(tree, o.From)
+		if err != nil {
+			return nil, err
+		}
+		return addPath(tree, o.Path, value)
+	case PatchTest:
+		value, err := o.Path.Get(tree)
+		if err != nil {
+			return nil, err
+		}
+		if !Equal(value, o.Value) {
+			return nil, fmt.Errorf("test failed")
+		}
+		return tree, nil
+	default:
+		return nil, fmt.Errorf("unknown operation '%s'", o.Op)
+	}
+}
+
+func addPath(tree NbtTag, p Path, value NbtTag) (NbtTag, error) {
+	if value == nil {
+		return nil, fmt.Errorf("missing value")
+	}
+	if len(p) == 0 {
+		return value, nil
+	}
+	return updatePath(tree, p[:len(p)-1], func(parent NbtTag) (NbtTag, error) {
+		return withChild(parent, p[len(p)-1], value, true)
+	})
+}
+
+// removePath removes the tag at p from tree and returns the updated tree and the removed tag.
+// Removing the root tag results in a nil tree.
+func removePath(tree NbtTag, p Path) (NbtTag, NbtTag, error) {
+	if len(p) == 0 {
+		return nil, tree, nil
+	}
+	var removed NbtTag
+	tree, err := updatePath(tree, p[:len(p)-1], func(parent NbtTag) (NbtTag, error) {
+		var err error
+		parent, removed, err = withoutChild(parent, p[len(p)-1])
+		return parent, err
+	})
+	return tree, removed, err
+}
+
+// CreatePatch returns a patch, that turns the tree a into the tree b when applied with
+// [ApplyPatch]. The order of compound keys is kept as well.
+func CreatePatch(a, b NbtTag) Patch {
+	changes := Diff(a, b)
+	patch := Patch{}
+	for i := 0; i < len(changes); i++ {
+		c := changes[i]
+		switch c.Kind {
+		case Added:
+			patch = append(patch, Operation{Op: PatchAdd, Path: c.Path, Value: c.New})
+		case Changed, TypeChanged:
+			patch = append(patch, Operation{Op: PatchReplace, Path: c.Path, Value: c.New})
+		case Removed:
+			// trailing list elements are reported in ascending order, but have to be removed from
+			// the end, so the indices stay valid
+			j := i
+			for j+1 < len(changes) && isNextRemovedElement(c, changes[j+1]) {
+				j++
+			}
+			for k := j; k >= i; k-- {
+				patch = append(patch, Operation{Op: PatchRemove, Path: changes[k].Path})
+			}
+			i = j
+		}
+	}
+	orderPatch(&patch, Path{}, a, b)
+	return patch
+}
+
+// isNextRemovedElement reports whether next removes an element of the same list as c.
+func isNextRemovedElement(c, next Change) bool {
+	n := len(c.Path)
+	return next.Kind == Removed && n > 0 && len(next.Path) == n &&
+		c.Path[n-1].IsIndex && next.Path[n-1].IsIndex &&
+		slices.Equal(c.Path[:n-1], next.Path[:n-1])
+}
+
+// orderPatch appends move operations to patch, that bring the keys of all compounds in the same
+// order as in b. It expects the keys of a to be in their order after the removals and additions
+// of the patch, i.e. removed keys are gone and new keys are appended.
+func orderPatch(patch *Patch, p Path, a, b NbtTag) {
+	switch a := a.(type) {
+	case Compound:
+		b, ok := b.(Compound)
+		if !ok {
+			return
+		}
+		var current []String
+		for _, k := range a.Keys() {
+			if _, ok := b[k]; ok {
+				current = append(current, k)
+			}
+		}
+		for _, k := range b.Keys() {
+			if _, ok := a[k]; !ok {
+				current = append(current, k)
+			}
+		}
+
+		bKeys := b.Keys()
+		i := 0
+		for i < len(bKeys) && current[i] == bKeys[i] {
+			i++
+		}
+		for _, k := range bKeys[i:] {
+			*patch = append(*patch, Operation{Op: PatchMove, From: p.Key(k), Path: p.Key(k)})
+		}
+
+		for _, k := range a.Keys() {
+			if bEntry, ok := b[k]; ok {
+				orderPatch(patch, p.Key(k), a[k].Value, bEntry.Value)
+			}
+		}
+	case List:
+		b, ok := b.(List)
+		if !ok || a.TagType != b.TagType {
+			return
+		}
+		for i := 0; i < min(len(a.Elements), len(b.Elements)); i++ {
+			orderPatch(patch, p.Index(i), a.Elements[i], b.Elements[i])
+		}
+	}
+}
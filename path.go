@@ -0,0 +1,353 @@
+package nbtreader
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// PathElement is a single step in a [Path]. It either selects an entry of a compound by its key or
+// an element of a list or array by its index.
+type PathElement struct {
+	Key     String
+	Index   int
+	IsIndex bool
+}
+
+// Path addresses a single tag inside a tree of tags, e.g. Level.Sections[3].BlockStates. An empty
+// path addresses the root tag itself.
+type Path []PathElement
+
+// Key returns a copy of p extended by the compound key k.
+func (p Path) Key(k String) Path {
+	return append(p[:len(p):len(p)], PathElement{Key: k})
+}
+
+// Index returns a copy of p extended by the list or array index i.
+func (p Path) Index(i int) Path {
+	return append(p[:len(p):len(p)], PathElement{Index: i, IsIndex: true})
+}
+
+// Display returns p as string like String, but "(root)" for the empty path of the root tag, so it
+// can be shown in messages.
+func (p Path) Display() string {
+	if len(p) == 0 {
+		return "(root)"
+	}
+	return p.String()
+}
+
+// String implements the fmt.Stringer interface. Keys that contain characters other than letters,
+// digits, '_', '-' and '+' are quoted.
+func (p Path) String() string {
+	var b strings.Builder
+	for i, e := range p {
+		if e.IsIndex {
+			b.WriteByte('[')
+			b.WriteString(strconv.Itoa(e.Index))
+			b.WriteByte(']')
+			continue
+		}
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		if isPlainKey(string(e.Key)) {
+			b.WriteString(string(e.Key))
+		} else {
+			b.WriteString(quoteString(string(e.Key)))
+		}
+	}
+	return b.String()
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (p Path) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (p *Path) UnmarshalText(text []byte) error {
+	path, err := ParsePath(string(text))
+	if err != nil {
+		return err
+	}
+	*p = path
+	return nil
+}
+
+// ParsePath parses a path in the format returned by [Path.String], e.g.
+// Level.Sections[3].BlockStates or "nested compound test".ham. The empty string is the path to the
+// root tag.
+func ParsePath(s string) (Path, error) {
+	p := Path{}
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == '[':
+			end := strings.IndexByte(s[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("path: missing ']' at offset %d", i)
+			}
+			index, err := strconv.Atoi(s[i+1 : i+end])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("path: invalid index '%s' at offset %d", s[i+1:i+end], i)
+			}
+			p = p.Index(index)
+			i += end + 1
+			continue
+		case c == '.' && len(p) > 0 && i+1 < len(s):
+			i++
+		case c == '.':
+			return nil, fmt.Errorf("path: unexpected '.' at offset %d", i)
+		}
+
+		if i < len(s) && s[i] == '"' {
+			var key strings.Builder
+			for i++; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				key.WriteByte(s[i])
+			}
+			if i >= len(s) {
+				return nil, fmt.Errorf("path: unterminated quoted key")
+			}
+			i++
+			p = p.Key(String(key.String()))
+			continue
+		}
+
+		end := strings.IndexAny(s[i:], ".[")
+		if end < 0 {
+			end = len(s) - i
+		}
+		if end == 0 {
+			return nil, fmt.Errorf("path: empty key at offset %d", i)
+		}
+		p = p.Key(String(s[i : i+end]))
+		i += end
+	}
+	return p, nil
+}
+
+// isPlainKey reports whether the key k can be written without quotes in a path.
+func isPlainKey(k string) bool {
+	if k == "" {
+		return false
+	}
+	for _, r := range k {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '_', r == '-', r == '+':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// quoteString surrounds s with double quotes and escapes any backslash and double quote in it.
+func quoteString(s string) string {
+	var b strings.Builder
+	b.Grow(len(s) + 2)
+	b.WriteByte('"')
+	// bytes are copied as they are, so invalid UTF-8 is kept as well
+	for i := 0; i < len(s); i++ {
+		if s[i] == '"' || s[i] == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// Get returns the tag at the path p inside tree.
+func (p Path) Get(tree NbtTag) (NbtTag, error) {
+	tag := tree
+	for i, e := range p {
+		var err error
+		if tag, err = child(tag, e); err != nil {
+			return nil, fmt.Errorf("%s: %v", p[:i+1], err)
+		}
+	}
+	return tag, nil
+}
+
+// updatePath replaces the tag at the path p inside tree with the result of f, which gets the
+// current tag. It returns the updated tree.
+func updatePath(tree NbtTag, p Path, f func(NbtTag) (NbtTag, error)) (NbtTag, error) {
+	if len(p) == 0 {
+		return f(tree)
+	}
+	c, err := child(tree, p[0])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", p[:1], err)
+	}
+	c, err = updatePath(c, p[1:], f)
+	if err != nil {
+		return nil, err
+	}
+	return withChild(tree, p[0], c, false)
+}
+
+// child returns the entry of the compound or the element of the list or array tag, which is
+// selected by e.
+func child(tag NbtTag, e PathElement) (NbtTag, error) {
+	if !e.IsIndex {
+		t, ok := tag.(Compound)
+		if !ok {
+			return nil, fmt.Errorf("cannot select key %s in %s", quoteString(string(e.Key)), typeOf(tag))
+		}
+		entry, ok := t[e.Key]
+		if !ok {
+			return nil, fmt.Errorf("key %s not found", quoteString(string(e.Key)))
+		}
+		return entry.Value, nil
+	}
+
+	if n := elementCount(tag); e.Index < 0 || e.Index >= n {
+		return nil, fmt.Errorf("index %d out of range for %s with %d elements", e.Index, typeOf(tag), n)
+	}
+	switch t := tag.(type) {
+	case List:
+		return t.Elements[e.Index], nil
+	case ByteArray:
+		return t[e.Index], nil
+	case IntArray:
+		return t[e.Index], nil
+	case LongArray:
+		return t[e.Index], nil
+	default:
+		return nil, fmt.Errorf("cannot select index in %s", typeOf(tag))
+	}
+}
+
+// withChild sets the entry of the compound or the element of the list or array tag, which is
+// selected by e, to v and returns the updated tag. If insert is set, list and array elements are
+// inserted at the index instead of replaced, where an index equal to the length appends.
+// Otherwise the entry or element must already exist, except for new compound keys.
+//
+// Like withoutChild, it changes a shallow copy of tag, so tag itself stays untouched.
+func withChild(tag NbtTag, e PathElement, v NbtTag, insert bool) (NbtTag, error) {
+	tag = shallowCopy(tag)
+	if !e.IsIndex {
+		t, ok := tag.(Compound)
+		if !ok {
+			return nil, fmt.Errorf("cannot set key %s in %s", quoteString(string(e.Key)), typeOf(tag))
+		}
+		t.Set(e.Key, v)
+		return t, nil
+	}
+
+	n := elementCount(tag)
+	if e.Index < 0 || e.Index > n || e.Index == n && !insert {
+		return nil, fmt.Errorf("index %d out of range for %s with %d elements", e.Index, typeOf(tag), n)
+	}
+	switch t := tag.(type) {
+	case List:
+		if len(t.Elements) == 0 && insert {
+			t.TagType = v.Type()
+		} else if v.Type() != t.TagType {
+			return nil, fmt.Errorf("cannot put %s in list of %s", v.Type(), t.TagType)
+		}
+		t.Elements = setElement(t.Elements, e.Index, v, insert)
+		return t, nil
+	case ByteArray:
+		item, ok := v.(Byte)
+		if !ok {
+			return nil, fmt.Errorf("cannot put %s in %s", v.Type(), t.Type())
+		}
+		return ByteArray(setElement(t, e.Index, item, insert)), nil
+	case IntArray:
+		item, ok := v.(Int)
+		if !ok {
+			return nil, fmt.Errorf("cannot put %s in %s", v.Type(), t.Type())
+		}
+		return IntArray(setElement(t, e.Index, item, insert)), nil
+	case LongArray:
+		item, ok := v.(Long)
+		if !ok {
+			return nil, fmt.Errorf("cannot put %s in %s", v.Type(), t.Type())
+		}
+		return LongArray(setElement(t, e.Index, item, insert)), nil
+	default:
+		return nil, fmt.Errorf("cannot set index in %s", typeOf(tag))
+	}
+}
+
+// withoutChild removes the entry of the compound or the element of the list or array tag, which
+// is selected by e. It returns the updated tag and the removed entry or element.
+func withoutChild(tag NbtTag, e PathElement) (NbtTag, NbtTag, error) {
+	old, err := child(tag, e)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch t := shallowCopy(tag).(type) {
+	case Compound:
+		t.Delete(e.Key)
+		return t, old, nil
+	case List:
+		t.Elements = slices.Delete(t.Elements, e.Index, e.Index+1)
+		return t, old, nil
+	case ByteArray:
+		return slices.Delete(t, e.Index, e.Index+1), old, nil
+	case IntArray:
+		return slices.Delete(t, e.Index, e.Index+1), old, nil
+	case LongArray:
+		return slices.Delete(t, e.Index, e.Index+1), old, nil
+	default:
+		return nil, nil, fmt.Errorf("cannot remove from %s", typeOf(tag))
+	}
+}
+
+// shallowCopy returns a copy of a compound, list or array tag, that shares the children with tag,
+// but not the map or slice itself. Other tags are returned as is.
+func shallowCopy(tag NbtTag) NbtTag {
+	switch t := tag.(type) {
+	case Compound:
+		return maps.Clone(t)
+	case List:
+		return List{TagType: t.TagType, Elements: slices.Clone(t.Elements)}
+	case ByteArray:
+		return slices.Clone(t)
+	case IntArray:
+		return slices.Clone(t)
+	case LongArray:
+		return slices.Clone(t)
+	default:
+		return tag
+	}
+}
+
+func setElement[S ~[]E, E any](s S, i int, v E, insert bool) S {
+	if insert {
+		return slices.Insert(s, i, v)
+	}
+	s[i] = v
+	return s
+}
+
+// elementCount returns the number of elements of a list or array tag, or 0 for any other tag.
+func elementCount(tag NbtTag) int {
+	switch t := tag.(type) {
+	case List:
+		return len(t.Elements)
+	case ByteArray:
+		return len(t)
+	case IntArray:
+		return len(t)
+	case LongArray:
+		return len(t)
+	default:
+		return 0
+	}
+}
+
+func typeOf(tag NbtTag) string {
+	if tag == nil {
+		return "nothing"
+	}
+	return tag.Type().String()
+}
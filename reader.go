@@ -0,0 +1,79 @@
+package nbtreader
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// Reader reads a sequence of concatenated root tags, as found in some mod formats and packet
+// dumps. The whole sequence may be gzip compressed, also as concatenated gzip files.
+type Reader struct {
+	// src holds the decompressed data and the options
+	src *NBT
+	d   *decoder
+	err error
+}
+
+// NewReader returns a Reader, that reads root tags from r. The NBT objects returned by
+// [Reader.Next] write to w, when composed. The options are applied to each root tag, except
+// [Strict], as trailing data is the next root tag.
+func NewReader(r io.Reader, w io.Writer, opts ...Option) *Reader {
+	src := &NBT{
+		w: w,
+		rw: bufio.NewReadWriter(
+			bufio.NewReader(r),
+			bufio.NewWriter(w),
+		),
+	}
+	for _, opt := range opts {
+		opt(&src.opts)
+	}
+	return &Reader{src: src}
+}
+
+// Next parses the next root tag. It returns io.EOF, if the data ends after the previous root
+// tag. After any error, Next returns the same error again.
+func (r *Reader) Next() (*NBT, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.d == nil {
+		if err := r.src.decompress(); err != nil {
+			r.err = fmt.Errorf("nbt: %v", err)
+			return nil, r.err
+		}
+		r.d = newDecoder(r.src.rw, r.src.opts)
+	}
+
+	// the data may only end between two root tags
+	if _, err := r.src.rw.Peek(1); err == io.EOF {
+		r.err = io.EOF
+		return nil, r.err
+	}
+
+	nbt := &NBT{
+		w:           r.src.w,
+		rw:          bufio.NewReadWriter(nil, bufio.NewWriter(r.src.w)),
+		compression: r.src.compression,
+		opts:        r.src.opts,
+	}
+	r.d.path, r.d.truncations, r.d.size, r.d.duplicates = nil, nil, 0, nil
+	if err := nbt.parseRoot(r.d); err != nil {
+		r.err = err
+		return nil, r.err
+	}
+	if r.d.lossless {
+		nbt.lossless = &lossless{duplicates: r.d.duplicates}
+	}
+	return nbt, nil
+}
+
+// Offset returns the number of decompressed bytes read so far, which is the offset of the next
+// root tag.
+func (r *Reader) Offset() int64 {
+	if r.d == nil {
+		return 0
+	}
+	return r.d.offset
+}
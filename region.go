@@ -0,0 +1,137 @@
+package nbtreader
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	regionSectorSize = 4096
+	regionChunks     = 32 * 32
+)
+
+// chunk compression types used in region files
+const (
+	chunkGZIP byte = 1
+	chunkZLIB byte = 2
+	chunkNONE byte = 3
+	chunkLZ4  byte = 4
+	// chunkExternal is set in addition to the compression type, if the chunk is stored in a
+	// separate .mcc file
+	chunkExternal byte = 128
+)
+
+// Region is a Minecraft region file (.mca), that stores up to 32x32 chunks.
+type Region struct {
+	r          io.ReaderAt
+	locations  [regionChunks]uint32
+	timestamps [regionChunks]uint32
+	opts       []Option
+}
+
+// OpenRegion reads the header of the region file r. The chunks are read from r when requested and
+// parsed with the given options, e.g. [WithLimits].
+func OpenRegion(r io.ReaderAt, opts ...Option) (*Region, error) {
+	var header [2 * regionSectorSize]byte
+	if _, err := r.ReadAt(header[:], 0); err != nil {
+		return nil, fmt.Errorf("region: reading header: %v", err)
+	}
+
+	region := &Region{r: r, opts: opts}
+	for i := 0; i < regionChunks; i++ {
+		region.locations[i] = binary.BigEndian.Uint32(header[4*i:])
+		region.timestamps[i] = binary.BigEndian.Uint32(header[regionSectorSize+4*i:])
+	}
+	return region, nil
+}
+
+// chunkIndex returns the index of the chunk in the header. x and z are taken modulo 32, so world
+// chunk coordinates can be used as well.
+func chunkIndex(x, z int) int {
+	return (x & 31) + (z&31)*32
+}
+
+// HasChunk reports whether the chunk at x, z exists in the region.
+func (r *Region) HasChunk(x, z int) bool {
+	return r.locations[chunkIndex(x, z)] != 0
+}
+
+// Timestamp returns the time the chunk at x, z was last saved.
+func (r *Region) Timestamp(x, z int) time.Time {
+	return time.Unix(int64(r.timestamps[chunkIndex(x, z)]), 0)
+}
+
+// Chunk reads and parses the chunk at x, z. The coordinates are taken modulo 32, so local (0-31)
+// as well as world chunk coordinates can be used. It returns nil and no error if the chunk does
+// not exist.
+func (r *Region) Chunk(x, z int) (*NBT, error) {
+	chunk, err := r.chunkReader(x, z)
+	if chunk == nil {
+		return nil, err
+	}
+	nbt, err := New(chunk, nil, r.opts...)
+	if err != nil {
+		return nil, fmt.Errorf("region: chunk %d, %d: %v", x, z, err)
+	}
+	return nbt, nil
+}
+
+// chunkReader returns the decompressed data of the chunk at x, z, or nil and no error if the chunk
+// does not exist. The data is limited to the MaxDecompressedSize of the options of the region.
+func (r *Region) chunkReader(x, z int) (io.Reader, error) {
+	location := r.locations[chunkIndex(x, z)]
+	if location == 0 {
+		return nil, nil
+	}
+	offset := int64(location>>8) * regionSectorSize
+	sectors := int64(location & 0xff)
+
+	var header [5]byte
+	if _, err := r.r.ReadAt(header[:], offset); err != nil {
+		return nil, fmt.Errorf("region: chunk %d, %d: %v", x, z, err)
+	}
+	length := int64(binary.BigEndian.Uint32(header[:4]))
+	if length < 1 || length+4 > sectors*regionSectorSize {
+		return nil, fmt.Errorf("region: chunk %d, %d: invalid length %d for %d sectors", x, z, length, sectors)
+	}
+	data := io.NewSectionReader(r.r, offset+5, length-1)
+
+	var o options
+	for _, opt := range r.opts {
+		opt(&o)
+	}
+	var chunk io.Reader
+	switch c := header[4]; c {
+	case chunkGZIP:
+		gzipReader, err := gzip.NewReader(data)
+		if err != nil {
+			return nil, fmt.Errorf("region: chunk %d, %d: %v", x, z, err)
+		}
+		chunk = gzipReader
+	case chunkZLIB:
+		zlibReader, err := zlib.NewReader(data)
+		if err != nil {
+			return nil, fmt.Errorf("region: chunk %d, %d: %v", x, z, err)
+		}
+		chunk = zlibReader
+	case chunkNONE:
+		chunk = data
+	case chunkLZ4:
+		lz4Reader := newLZ4Reader(data)
+		lz4Reader.max = o.limits.MaxDecompressedSize
+		chunk = lz4Reader
+	default:
+		if c&chunkExternal != 0 {
+			return nil, fmt.Errorf("region: chunk %d, %d: chunk is stored in an external file", x, z)
+		}
+		return nil, fmt.Errorf("region: chunk %d, %d: unsupported compression %d", x, z, c)
+	}
+	if max := o.limits.MaxDecompressedSize; max > 0 {
+		chunk = &limitedReader{r: chunk, n: max, max: max}
+	}
+	return chunk, nil
+}
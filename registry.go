@@ -0,0 +1,198 @@
+package nbtreader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrFormat is returned by [DecodeFormat], [DecodeAs] and [EncodeAs] for data in an unknown
+// format, or a format, that cannot be read or written.
+var ErrFormat = errors.New("nbt: unknown format")
+
+// registeredFormat is a format added with RegisterFormat.
+type registeredFormat struct {
+	name string
+	// format is set for the built-in formats, which are detected by DetectFormat instead of detect
+	format Format
+	detect func(data []byte) bool
+	decode func(data []byte) (NbtTag, error)
+	encode func(tag NbtTag) ([]byte, error)
+}
+
+var (
+	formatsMu sync.RWMutex
+	formats   []registeredFormat
+)
+
+func init() {
+	registerBuiltin("nbt", FormatNBT, func(data []byte) (NbtTag, error) {
+		return decodeBinary(data)
+	}, func(tag NbtTag) ([]byte, error) {
+		return encodeBinary(tag, GZIP)
+	})
+	registerBuiltin("bedrock", FormatBedrock, func(data []byte) (NbtTag, error) {
+		// the header of level.dat is skipped, if there is one
+		if format, _ := DetectFormat(data); format == FormatBedrockLevel {
+			return decodeBinary(data, BedrockLevel())
+		}
+		return decodeBinary(data, ByteOrder(binary.LittleEndian))
+	}, func(tag NbtTag) ([]byte, error) {
+		return encodeBinary(tag, NONE, ByteOrder(binary.LittleEndian))
+	})
+	registerBuiltin("bedrock-level", FormatBedrockLevel, func(data []byte) (NbtTag, error) {
+		return decodeBinary(data, BedrockLevel())
+	}, func(tag NbtTag) ([]byte, error) {
+		return encodeBinary(tag, NONE, BedrockLevel())
+	})
+	registerBuiltin("snbt", FormatSNBT, ParseSNBT, func(tag NbtTag) ([]byte, error) {
+		b, err := MarshalSNBTIndent(tag)
+		return append(b, '\n'), err
+	})
+	registerBuiltin("json", FormatJSON, ParseJSON, func(tag NbtTag) ([]byte, error) {
+		b, err := json.MarshalIndent(tag, "", "\t")
+		return append(b, '\n'), err
+	})
+	registerBuiltin("njson", FormatNJSON, ParseNJSON, func(tag NbtTag) ([]byte, error) {
+		b, err := MarshalNJSON(tag)
+		return append(b, '\n'), err
+	})
+}
+
+// RegisterFormat adds a format, that is read and written as a whole, under the given name. detect
+// reports whether data is in the format. decode parses the data to a tree and encode writes a tree.
+// Any of them may be nil, if the format cannot be detected, read or written.
+//
+// The built-in formats are nbt, bedrock, bedrock-level, snbt, json and njson. Formats registered
+// later are detected first, so they can refine the built-in ones, e.g. a JSON format with a schema
+// of its own. Registering an existing name replaces the format. Names are case-insensitive.
+func RegisterFormat(name string, detect func(data []byte) bool, decode func(data []byte) (NbtTag, error), encode func(tag NbtTag) ([]byte, error)) {
+	register(registeredFormat{name: strings.ToLower(name), detect: detect, decode: decode, encode: encode})
+}
+
+func registerBuiltin(name string, format Format, decode func(data []byte) (NbtTag, error), encode func(tag NbtTag) ([]byte, error)) {
+	register(registeredFormat{name: name, format: format, decode: decode, encode: encode})
+}
+
+func register(f registeredFormat) {
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+	for i, old := range formats {
+		if old.name == f.name {
+			formats = append(formats[:i:i], formats[i+1:]...)
+			break
+		}
+	}
+	formats = append(formats, f)
+}
+
+// registered returns the registered formats. The functions of the formats are called without
+// holding the lock, so they may use the registry themselves.
+func registered() []registeredFormat {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	return formats[:len(formats):len(formats)]
+}
+
+// Formats returns the names of all registered formats, sorted by name.
+func Formats() []string {
+	var names []string
+	for _, f := range registered() {
+		names = append(names, f.name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupFormat returns the registered format with the given name.
+func lookupFormat(name string) (registeredFormat, bool) {
+	name = strings.ToLower(name)
+	for _, f := range registered() {
+		if f.name == name {
+			return f, true
+		}
+	}
+	return registeredFormat{}, false
+}
+
+// DetectFormatName returns the name of the registered format of data, or "" if none matches.
+// Region files and archives are not registered formats, use [DetectFormat] for them.
+func DetectFormatName(data []byte) string {
+	formats := registered()
+	// the built-in formats share a single call of DetectFormat
+	var detected *Format
+	for i := len(formats) - 1; i >= 0; i-- {
+		f := formats[i]
+		switch {
+		case f.detect != nil:
+			if f.detect(data) {
+				return f.name
+			}
+		case f.format != FormatUnknown:
+			if detected == nil {
+				format, _ := DetectFormat(data)
+				detected = &format
+			}
+			if *detected == f.format {
+				return f.name
+			}
+		}
+	}
+	return ""
+}
+
+// DecodeFormat detects the format of data with [DetectFormatName] and decodes it. It returns the
+// tree and the name of the format.
+func DecodeFormat(data []byte) (NbtTag, string, error) {
+	name := DetectFormatName(data)
+	if name == "" {
+		return nil, "", ErrFormat
+	}
+	tag, err := DecodeAs(name, data)
+	return tag, name, err
+}
+
+// DecodeAs decodes data in the registered format with the given name.
+func DecodeAs(name string, data []byte) (NbtTag, error) {
+	f, ok := lookupFormat(name)
+	if !ok || f.decode == nil {
+		return nil, fmt.Errorf("%w: cannot read %q", ErrFormat, name)
+	}
+	return f.decode(data)
+}
+
+// EncodeAs encodes tag in the registered format with the given name. Binary NBT is written with
+// an empty root name, compressed with gzip for Java and uncompressed for Bedrock. Text formats end
+// with a line break.
+func EncodeAs(name string, tag NbtTag) ([]byte, error) {
+	f, ok := lookupFormat(name)
+	if !ok || f.encode == nil {
+		return nil, fmt.Errorf("%w: cannot write %q", ErrFormat, name)
+	}
+	return f.encode(tag)
+}
+
+func decodeBinary(data []byte, opts ...Option) (NbtTag, error) {
+	nbt, err := New(bytes.NewReader(data), nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return nbt.Root(), nil
+}
+
+func encodeBinary(tag NbtTag, c Compression, opts ...Option) ([]byte, error) {
+	var buf bytes.Buffer
+	nbt, err := FromRoot(tag, &buf, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err = nbt.Compose(c); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
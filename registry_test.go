@@ -0,0 +1,31 @@
+package nbtreader
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestEncodeAsSNBTRoundTrip(t *testing.T) {
+	data, err := os.ReadFile("files/bigtest.nbt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nbt, err := New(bytes.NewReader(data), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snbt, err := EncodeAs("snbt", nbt.Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the keys of bigtest.nbt contain spaces and parentheses
+	tag, err := DecodeAs("snbt", snbt)
+	if err != nil {
+		t.Fatalf("reading the encoded SNBT: %v", err)
+	}
+	if !Equal(tag, nbt.Root()) {
+		t.Errorf("the encoded SNBT is read to another tree:\n%s", snbt)
+	}
+}
@@ -0,0 +1,92 @@
+package nbtreader
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// Salvage enables the best-effort parsing of truncated or corrupted data. Instead of failing,
+// [New] keeps everything parsed up to the first error, closes all compounds and lists, that were
+// open at that point, and describes the loss in a [SalvageReport], available by [NBT.Salvaged].
+//
+// Errors before the root tag, e.g. a broken compression header, are still returned.
+func Salvage() Option {
+	return func(o *options) {
+		o.salvage = true
+	}
+}
+
+// SalvageReport describes what was lost while parsing data in salvage mode.
+type SalvageReport struct {
+	// Err is the error, that stopped the parsing.
+	Err *ParseError
+	// Dropped is the path of the tag, that was only partially read and therefore dropped. It is
+	// nil if no single tag was dropped, e.g. because the data ended between two tags.
+	Dropped Path
+	// Truncated lists all compounds and lists, that were closed early, the innermost first.
+	Truncated []Truncation
+	// Unparsed is the number of bytes after the failure point, that were skipped.
+	Unparsed int64
+}
+
+// Truncation describes a compound or list, that was closed early in salvage mode.
+type Truncation struct {
+	Path Path
+	Type TagType
+	// Parsed is the number of entries, that were kept.
+	Parsed int
+	// Expected is the number of entries the list announced, or -1 if it is unknown, like for
+	// compounds.
+	Expected int
+}
+
+// String implements the fmt.Stringer interface.
+func (t Truncation) String() string {
+	if t.Expected < 0 {
+		return fmt.Sprintf("%s (%s): kept %d entries", t.Path.Display(), typeName(t.Type), t.Parsed)
+	}
+	return fmt.Sprintf("%s (%s): kept %d of %d entries", t.Path.Display(), typeName(t.Type), t.Parsed, t.Expected)
+}
+
+// String implements the fmt.Stringer interface. The report is formatted over multiple lines.
+func (r *SalvageReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%v\n", r.Err)
+	if r.Dropped != nil {
+		fmt.Fprintf(&b, "dropped %s\n", r.Dropped.Display())
+	}
+	for _, t := range r.Truncated {
+		fmt.Fprintf(&b, "truncated %s\n", t)
+	}
+	if r.Unparsed > 0 {
+		fmt.Fprintf(&b, "skipped %d bytes after the error\n", r.Unparsed)
+	}
+	return b.String()
+}
+
+// Salvaged returns the report of the data lost while parsing in salvage mode. It is nil, if the
+// data was parsed completely or salvage mode was not enabled.
+func (nbt NBT) Salvaged() *SalvageReport {
+	return nbt.salvaged
+}
+
+// salvage builds the report after the root tag failed to parse with err and skips the rest of the
+// data. It returns err again if nothing could be salvaged.
+func (nbt *NBT) salvage(d *decoder, root NbtTag, err error) error {
+	parseErr, ok := err.(*ParseError)
+	if !ok || !isContainer(root) {
+		return err
+	}
+
+	report := &SalvageReport{Err: parseErr, Truncated: d.truncations}
+	if len(d.truncations) == 0 || !slices.Equal(d.truncations[0].Path, parseErr.Path) {
+		report.Dropped = parseErr.Path
+	}
+	report.Unparsed, _ = io.Copy(io.Discard, d.r)
+
+	nbt.root = root
+	nbt.salvaged = report
+	return nil
+}
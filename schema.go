@@ -0,0 +1,257 @@
+package nbtreader
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"unicode/utf8"
+)
+
+// Schema describes the expected structure of a tree of tags. All fields are optional, an empty
+// schema accepts any tag. Schemas are stored as JSON, e.g.
+//
+//	{
+//		"type": "compound",
+//		"required": ["id", "Count"],
+//		"keys": {
+//			"id": {"type": "string", "pattern": "^minecraft:[a-z_]+$"},
+//			"Count": {"type": "byte", "min": 1, "max": 64},
+//			"tag": {"type": "compound"}
+//		},
+//		"closed": true
+//	}
+type Schema struct {
+	// Description documents the tag and is not used for validation.
+	Description string `json:"description,omitempty"`
+	// Type is the type of the tag. Tag_End, the default, allows any type.
+	Type TagType `json:"type,omitempty"`
+
+	// Keys holds the schemas of the known entries of a compound.
+	Keys map[string]*Schema `json:"keys,omitempty"`
+	// Required lists the keys a compound must have.
+	Required []String `json:"required,omitempty"`
+	// Values is the schema of all entries of a compound, that are not listed in Keys.
+	Values *Schema `json:"values,omitempty"`
+	// Closed disallows entries of a compound, that are not listed in Keys.
+	Closed bool `json:"closed,omitempty"`
+
+	// Elements is the schema of the elements of a list or array.
+	Elements *Schema `json:"elements,omitempty"`
+
+	// MinLength and MaxLength limit the length of strings in bytes, the number of elements of
+	// lists and arrays and the number of entries of compounds.
+	MinLength *int `json:"minLength,omitempty"`
+	MaxLength *int `json:"maxLength,omitempty"`
+
+	// Min and Max limit the value of numbers.
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+
+	// Pattern is a regular expression, that strings must match.
+	Pattern string `json:"pattern,omitempty"`
+	// Enum lists the allowed values of strings or numbers.
+	Enum []any `json:"enum,omitempty"`
+}
+
+// Violation is a single mismatch between a tree of tags and a [Schema].
+type Violation struct {
+	Path    Path
+	Message string
+}
+
+// Error implements the error interface.
+func (v Violation) Error() string {
+	if len(v.Path) == 0 {
+		return v.Message
+	}
+	return v.Path.String() + ": " + v.Message
+}
+
+// LoadSchema reads a JSON encoded schema from r. Unknown fields and invalid patterns are
+// reported as errors.
+func LoadSchema(r io.Reader) (*Schema, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	s := &Schema{}
+	if err := dec.Decode(s); err != nil {
+		return nil, fmt.Errorf("schema: %v", err)
+	}
+	if err := s.check(Path{}); err != nil {
+		return nil, fmt.Errorf("schema: %v", err)
+	}
+	return s, nil
+}
+
+// check verifies the patterns of s and all its sub schemas.
+func (s *Schema) check(p Path) error {
+	if s == nil {
+		return nil
+	}
+	if _, err := regexp.Compile(s.Pattern); err != nil {
+		return fmt.Errorf("%s: %v", p, err)
+	}
+	for k, sub := range s.Keys {
+		if err := sub.check(p.Key(String(k))); err != nil {
+			return err
+		}
+	}
+	if err := s.Values.check(p.Key("*")); err != nil {
+		return err
+	}
+	return s.Elements.check(p.Index(0))
+}
+
+// Validate checks tree against the schema and returns all violations. It returns nil if the tree
+// matches the schema.
+func (s *Schema) Validate(tree NbtTag) []Violation {
+	v := &schemaValidator{patterns: map[string]*regexp.Regexp{}}
+	v.validate(s, Path{}, tree)
+	return v.violations
+}
+
+type schemaValidator struct {
+	violations []Violation
+	patterns   map[string]*regexp.Regexp
+}
+
+func (v *schemaValidator) addf(p Path, format string, a ...any) {
+	v.violations = append(v.violations, Violation{Path: p, Message: fmt.Sprintf(format, a...)})
+}
+
+func (v *schemaValidator) validate(s *Schema, p Path, tag NbtTag) {
+	if s == nil {
+		return
+	}
+	if tag == nil {
+		v.addf(p, "missing tag")
+		return
+	}
+	if s.Type != Tag_End && tag.Type() != s.Type {
+		v.addf(p, "expected %s, got %s", typeName(s.Type), typeName(tag.Type()))
+		return
+	}
+
+	switch t := tag.(type) {
+	case Compound:
+		v.validateLength(s, p, len(t))
+		for _, k := range s.Required {
+			if _, ok := t[k]; !ok {
+				v.addf(p.Key(k), "missing required key")
+			}
+		}
+		for _, k := range t.Keys() {
+			if sub, ok := s.Keys[string(k)]; ok {
+				v.validate(sub, p.Key(k), t[k].Value)
+			} else if s.Closed {
+				v.addf(p.Key(k), "unknown key")
+			} else {
+				v.validate(s.Values, p.Key(k), t[k].Value)
+			}
+		}
+	case List:
+		v.validateLength(s, p, len(t.Elements))
+		if s.Elements != nil && s.Elements.Type != Tag_End && len(t.Elements) > 0 && t.TagType != s.Elements.Type {
+			v.addf(p, "expected list of %s, got list of %s", typeName(s.Elements.Type), typeName(t.TagType))
+			return
+		}
+		for i, entry := range t.Elements {
+			v.validate(s.Elements, p.Index(i), entry)
+		}
+	case ByteArray:
+		validateArray(v, s, p, t)
+	case IntArray:
+		validateArray(v, s, p, t)
+	case LongArray:
+		validateArray(v, s, p, t)
+	case String:
+		v.validateLength(s, p, len(t))
+		if s.Pattern != "" {
+			re, ok := v.patterns[s.Pattern]
+			if !ok {
+				// patterns are checked by LoadSchema, but the schema may be built in code
+				re, _ = regexp.Compile(s.Pattern)
+				v.patterns[s.Pattern] = re
+			}
+			if re == nil {
+				v.addf(p, "invalid pattern '%s'", s.Pattern)
+			} else if !re.MatchString(string(t)) {
+				v.addf(p, "%s does not match pattern '%s'", shortSNBT(t), s.Pattern)
+			}
+		}
+		v.validateEnum(s, p, t, string(t))
+	case Byte:
+		v.validateNumber(s, p, t, float64(t))
+	case Short:
+		v.validateNumber(s, p, t, float64(t))
+	case Int:
+		v.validateNumber(s, p, t, float64(t))
+	case Long:
+		v.validateNumber(s, p, t, float64(t))
+	case Float:
+		v.validateNumber(s, p, t, float64(t))
+	case Double:
+		v.validateNumber(s, p, t, float64(t))
+	}
+}
+
+func validateArray[S ~[]E, E NbtTag](v *schemaValidator, s *Schema, p Path, t S) {
+	v.validateLength(s, p, len(t))
+	for i, item := range t {
+		v.validate(s.Elements, p.Index(i), item)
+	}
+}
+
+func (v *schemaValidator) validateLength(s *Schema, p Path, n int) {
+	if s.MinLength != nil && n < *s.MinLength {
+		v.addf(p, "length %d is less than %d", n, *s.MinLength)
+	}
+	if s.MaxLength != nil && n > *s.MaxLength {
+		v.addf(p, "length %d is greater than %d", n, *s.MaxLength)
+	}
+}
+
+func (v *schemaValidator) validateNumber(s *Schema, p Path, tag NbtTag, f float64) {
+	if s.Min != nil && f < *s.Min {
+		v.addf(p, "%s is less than %g", tag, *s.Min)
+	}
+	if s.Max != nil && f > *s.Max {
+		v.addf(p, "%s is greater than %g", tag, *s.Max)
+	}
+	v.validateEnum(s, p, tag, f)
+}
+
+// validateEnum checks value against the enum of s. Strings are compared to strings, numbers to
+// numbers.
+func (v *schemaValidator) validateEnum(s *Schema, p Path, tag NbtTag, value any) {
+	if len(s.Enum) == 0 {
+		return
+	}
+	for _, e := range s.Enum {
+		if e == value {
+			return
+		}
+	}
+	v.addf(p, "%s is not one of the allowed values", shortSNBT(tag))
+}
+
+// typeName returns the name of t as used in schemas.
+func typeName(t TagType) string {
+	if name, ok := tagTypeNames[t]; ok {
+		return name
+	}
+	return t.String()
+}
+
+// shortSNBT returns the SNBT of tag for messages, shortened to a reasonable length.
+func shortSNBT(tag NbtTag) string {
+	b, err := MarshalSNBT(tag)
+	if err != nil {
+		return tag.Type().String()
+	}
+	s := string(b)
+	if utf8.RuneCountInString(s) > 64 {
+		s = string([]rune(s)[:61]) + "..."
+	}
+	return s
+}
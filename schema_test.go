@@ -0,0 +1,48 @@
+package nbtreader
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestInferredSchemaRejectsMismatch checks, that a schema inferred from a file and stored as JSON
+// still rejects a tree with a changed type, so the keys of Schema.Keys are plain JSON keys.
+func TestInferredSchemaRejectsMismatch(t *testing.T) {
+	data, err := os.ReadFile("files/bigtest.nbt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nbt, err := New(bytes.NewReader(data), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inf := NewInference()
+	inf.Add(nbt.Root())
+	b, err := json.Marshal(inf.Schema())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"shortTest":`) {
+		t.Fatalf("schema keys are not plain JSON keys: %s", b)
+	}
+	schema, err := LoadSchema(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if violations := schema.Validate(nbt.Root()); len(violations) > 0 {
+		t.Fatalf("the inferred file does not match its schema: %v", violations)
+	}
+	tree := Clone(nbt.Root()).(Compound)
+	tree.Set("shortTest", String("not a short"))
+	violations := schema.Validate(tree)
+	if len(violations) == 0 {
+		t.Fatal("a string for shortTest matches the schema")
+	}
+	if got := violations[0].Path.String(); got != "shortTest" {
+		t.Errorf("violation at %q, want shortTest", got)
+	}
+}
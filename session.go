@@ -0,0 +1,445 @@
+package nbtreader
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+)
+
+// EditOp is the kind of an [Edit].
+type EditOp string
+
+const (
+	// EditSet sets the tag at Path to Value. An existing compound key keeps its position, a new
+	// key is appended to the compound.
+	EditSet EditOp = "set"
+	// EditDelete removes the tag at Path.
+	EditDelete EditOp = "delete"
+	// EditRename renames the compound key at Path to To. The entry keeps its position.
+	EditRename EditOp = "rename"
+	// EditInsert inserts Value into a list or array at the index of Path. Inserting a compound key
+	// puts it at the position Index.
+	EditInsert EditOp = "insert"
+)
+
+// Edit is a single change of a [Session]. It records the previous value, so it can be undone.
+type Edit struct {
+	Op    EditOp
+	Path  Path
+	To    String
+	Value NbtTag
+	// Old is the previous tag at Path for set and delete. It is nil, if a set added a new key.
+	Old NbtTag
+	// Index is the position of a deleted compound key or the position to insert a key at.
+	Index int
+}
+
+// Transaction is a group of edits, that is undone and redone at once.
+type Transaction struct {
+	Name  string
+	Edits []Edit
+}
+
+// Journal is the list of committed transactions of a [Session], the oldest first. Encoded as
+// JSON, the values are stored as SNBT strings like in a [Patch].
+type Journal []Transaction
+
+// ErrNoTransaction is returned by [Session.Commit] and [Session.Rollback], if no transaction was
+// started.
+var ErrNoTransaction = errors.New("nbt: no transaction in progress")
+
+// Session edits the tree of an [NBT] object and records every edit, so it can be undone and
+// redone. Edits can be grouped into transactions. Outside of a transaction, every edit is a
+// transaction of its own.
+//
+// The tree must only be changed through the session, while it is used. Edits copy the changed
+// compounds and lists, so trees returned by [NBT.Root] earlier stay unchanged.
+type Session struct {
+	nbt    *NBT
+	done   []Transaction
+	undone []Transaction
+	// tx is the transaction in progress, or nil
+	tx *Transaction
+}
+
+// NewSession returns a Session, that edits the tree of nbt.
+func NewSession(nbt *NBT) *Session {
+	return &Session{nbt: nbt}
+}
+
+// Set sets the tag at the path p to a copy of value. The root tag is replaced with an empty path.
+// For compounds, new keys are added.
+func (s *Session) Set(p Path, value NbtTag) error {
+	return s.record(Edit{Op: EditSet, Path: p, Value: Clone(value)})
+}
+
+// Delete removes the tag at the path p.
+func (s *Session) Delete(p Path) error {
+	return s.record(Edit{Op: EditDelete, Path: p})
+}
+
+// Rename renames the compound key at the path p to key.
+func (s *Session) Rename(p Path, key String) error {
+	return s.record(Edit{Op: EditRename, Path: p, To: key})
+}
+
+// Insert inserts a copy of value into a list or array at the index of the path p. An index equal
+// to the length appends to it.
+func (s *Session) Insert(p Path, value NbtTag) error {
+	if len(p) == 0 || !p[len(p)-1].IsIndex {
+		return fmt.Errorf("nbt: edit: insert %s: path must end with an index", p)
+	}
+	return s.record(Edit{Op: EditInsert, Path: p, Value: Clone(value)})
+}
+
+// Begin starts a transaction with the given name. All following edits are undone and redone
+// together, once the transaction is committed.
+func (s *Session) Begin(name string) error {
+	if s.tx != nil {
+		return fmt.Errorf("nbt: transaction %q is already in progress", s.tx.Name)
+	}
+	s.tx = &Transaction{Name: name}
+	return nil
+}
+
+// Commit ends the transaction in progress and records it, unless it is empty.
+func (s *Session) Commit() error {
+	if s.tx == nil {
+		return ErrNoTransaction
+	}
+	tx := *s.tx
+	s.tx = nil
+	if len(tx.Edits) > 0 {
+		s.done = append(s.done, tx)
+		s.undone = nil
+	}
+	return nil
+}
+
+// Rollback undoes all edits of the transaction in progress and ends it.
+func (s *Session) Rollback() error {
+	if s.tx == nil {
+		return ErrNoTransaction
+	}
+	tx := *s.tx
+	s.tx = nil
+	return s.revert(tx)
+}
+
+// Undo undoes the last transaction. It reports false, if there is nothing to undo.
+func (s *Session) Undo() (bool, error) {
+	if s.tx != nil {
+		return false, fmt.Errorf("nbt: cannot undo during transaction %q", s.tx.Name)
+	}
+	if len(s.done) == 0 {
+		return false, nil
+	}
+	tx := s.done[len(s.done)-1]
+	if err := s.revert(tx); err != nil {
+		return false, err
+	}
+	s.done = s.done[:len(s.done)-1]
+	s.undone = append(s.undone, tx)
+	return true, nil
+}
+
+// Redo applies the last undone transaction again. It reports false, if there is nothing to redo.
+func (s *Session) Redo() (bool, error) {
+	if s.tx != nil {
+		return false, fmt.Errorf("nbt: cannot redo during transaction %q", s.tx.Name)
+	}
+	if len(s.undone) == 0 {
+		return false, nil
+	}
+	tx := s.undone[len(s.undone)-1]
+	for i, edit := range tx.Edits {
+		if _, err := s.apply(edit); err != nil {
+			// keep the tree consistent with the journal
+			s.revert(Transaction{Edits: tx.Edits[:i]})
+			return false, fmt.Errorf("nbt: redo %q: %v", tx.Name, err)
+		}
+	}
+	s.undone = s.undone[:len(s.undone)-1]
+	s.done = append(s.done, tx)
+	return true, nil
+}
+
+// Journal returns the committed transactions, that are not undone.
+func (s *Session) Journal() Journal {
+	return Journal(s.done).clone()
+}
+
+// Replay applies the transactions of the journal j again and records them, e.g. to restore a
+// session from a saved journal. If an edit fails, the edits of its transaction are undone and the
+// error is returned.
+func (s *Session) Replay(j Journal) error {
+	if s.tx != nil {
+		return fmt.Errorf("nbt: cannot replay during transaction %q", s.tx.Name)
+	}
+	for _, tx := range j {
+		if err := s.Begin(tx.Name); err != nil {
+			return err
+		}
+		for _, edit := range tx.Edits {
+			// the recorded previous values are replaced by the current ones
+			edit.Old, edit.Value = nil, Clone(edit.Value)
+			if err := s.record(edit); err != nil {
+				s.Rollback()
+				return err
+			}
+		}
+		if err := s.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// record applies edit and adds it to the transaction in progress, or records it as a transaction
+// of its own.
+func (s *Session) record(edit Edit) error {
+	edit, err := s.apply(edit)
+	if err != nil {
+		return fmt.Errorf("nbt: edit: %s %s: %v", edit.Op, edit.Path, err)
+	}
+	if s.tx != nil {
+		s.tx.Edits = append(s.tx.Edits, edit)
+		return nil
+	}
+	s.done = append(s.done, Transaction{Edits: []Edit{edit}})
+	s.undone = nil
+	return nil
+}
+
+// revert undoes the edits of tx in reverse order.
+func (s *Session) revert(tx Transaction) error {
+	for i := len(tx.Edits) - 1; i >= 0; i-- {
+		if _, err := s.apply(tx.Edits[i].inverse()); err != nil {
+			return fmt.Errorf("nbt: undo %q: %v", tx.Name, err)
+		}
+	}
+	return nil
+}
+
+// apply applies edit to the tree and returns it with the previous value and position filled in.
+func (s *Session) apply(edit Edit) (Edit, error) {
+	if len(edit.Path) == 0 {
+		if edit.Op != EditSet {
+			return edit, fmt.Errorf("cannot %s the root tag", edit.Op)
+		}
+		old := s.nbt.root
+		if err := s.nbt.SetRoot(edit.Value); err != nil {
+			return edit, err
+		}
+		edit.Old = old
+		return edit, nil
+	}
+
+	parent, last := edit.Path[:len(edit.Path)-1], edit.Path[len(edit.Path)-1]
+	root, err := updatePath(s.nbt.root, parent, func(tag NbtTag) (NbtTag, error) {
+		return edit.applyTo(tag, last)
+	})
+	if err != nil {
+		return edit, err
+	}
+	s.nbt.root = root
+	return edit, nil
+}
+
+// applyTo applies the edit to the parent of the edited tag, where e selects the tag. It fills in
+// the previous value and position.
+func (edit *Edit) applyTo(parent NbtTag, e PathElement) (NbtTag, error) {
+	switch edit.Op {
+	case EditSet:
+		if edit.Value == nil {
+			return nil, fmt.Errorf("missing value")
+		}
+		edit.Old, _ = child(parent, e)
+		return withChild(parent, e, edit.Value, false)
+	case EditDelete:
+		edit.Index = position(parent, e)
+		var err error
+		parent, edit.Old, err = withoutChild(parent, e)
+		return parent, err
+	case EditRename:
+		t, ok := parent.(Compound)
+		if !ok || e.IsIndex {
+			return nil, fmt.Errorf("cannot rename element of %s", typeOf(parent))
+		}
+		if _, ok := t[e.Key]; !ok {
+			return nil, fmt.Errorf("key %s not found", quoteString(string(e.Key)))
+		}
+		if _, ok := t[edit.To]; ok {
+			return nil, fmt.Errorf("key %s already exists", quoteString(string(edit.To)))
+		}
+		t = maps.Clone(t)
+		t[edit.To] = t[e.Key]
+		delete(t, e.Key)
+		return t, nil
+	case EditInsert:
+		if edit.Value == nil {
+			return nil, fmt.Errorf("missing value")
+		}
+		if e.IsIndex {
+			return withChild(parent, e, edit.Value, true)
+		}
+		return withKeyAt(parent, e.Key, edit.Value, edit.Index)
+	default:
+		return nil, fmt.Errorf("unknown edit '%s'", edit.Op)
+	}
+}
+
+// inverse returns the edit, that undoes edit.
+func (edit Edit) inverse() Edit {
+	switch edit.Op {
+	case EditSet:
+		if edit.Old == nil {
+			return Edit{Op: EditDelete, Path: edit.Path}
+		}
+		return Edit{Op: EditSet, Path: edit.Path, Value: edit.Old}
+	case EditDelete:
+		return Edit{Op: EditInsert, Path: edit.Path, Value: edit.Old, Index: edit.Index}
+	case EditRename:
+		p := edit.Path[:len(edit.Path)-1].Key(edit.To)
+		return Edit{Op: EditRename, Path: p, To: edit.Path[len(edit.Path)-1].Key}
+	case EditInsert:
+		return Edit{Op: EditDelete, Path: edit.Path}
+	default:
+		return edit
+	}
+}
+
+// position returns the position of the compound key or the index selected by e.
+func position(parent NbtTag, e PathElement) int {
+	if t, ok := parent.(Compound); ok && !e.IsIndex {
+		return t[e.Key].Index
+	}
+	return e.Index
+}
+
+// withKeyAt returns a copy of the compound tag with key added at the given position.
+func withKeyAt(tag NbtTag, key String, v NbtTag, index int) (NbtTag, error) {
+	t, ok := tag.(Compound)
+	if !ok {
+		return nil, fmt.Errorf("cannot set key %s in %s", quoteString(string(key)), typeOf(tag))
+	}
+	if _, ok := t[key]; ok {
+		return nil, fmt.Errorf("key %s already exists", quoteString(string(key)))
+	}
+	if index < 0 || index > len(t) {
+		index = len(t)
+	}
+	c := make(Compound, len(t)+1)
+	for k, entry := range t {
+		if entry.Index >= index {
+			entry.Index++
+		}
+		c[k] = entry
+	}
+	c[key] = struct {
+		Index int
+		Value NbtTag
+	}{index, v}
+	return c, nil
+}
+
+func (j Journal) clone() Journal {
+	c := make(Journal, len(j))
+	for i, tx := range j {
+		c[i] = Transaction{Name: tx.Name, Edits: append([]Edit(nil), tx.Edits...)}
+	}
+	return c
+}
+
+type jsonEdit struct {
+	Op    EditOp  `json:"op"`
+	Path  Path    `json:"path"`
+	To    *String `json:"to,omitempty"`
+	Value *string `json:"value,omitempty"`
+	Old   *string `json:"old,omitempty"`
+	Index *int    `json:"index,omitempty"`
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (edit Edit) MarshalJSON() ([]byte, error) {
+	v := jsonEdit{Op: edit.Op, Path: edit.Path}
+	if edit.Op == EditRename {
+		v.To = &edit.To
+	}
+	if edit.Op == EditDelete || edit.Op == EditInsert && len(edit.Path) > 0 && !edit.Path[len(edit.Path)-1].IsIndex {
+		v.Index = &edit.Index
+	}
+	var err error
+	if v.Value, err = snbtString(edit.Value); err != nil {
+		return nil, err
+	}
+	if v.Old, err = snbtString(edit.Old); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (edit *Edit) UnmarshalJSON(data []byte) error {
+	var v jsonEdit
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*edit = Edit{Op: v.Op, Path: v.Path}
+	if v.To != nil {
+		edit.To = *v.To
+	}
+	if v.Index != nil {
+		edit.Index = *v.Index
+	}
+	var err error
+	if edit.Value, err = parseSNBTString(v.Value); err != nil {
+		return fmt.Errorf("edit: value of %s %s: %v", edit.Op, edit.Path, err)
+	}
+	if edit.Old, err = parseSNBTString(v.Old); err != nil {
+		return fmt.Errorf("edit: old value of %s %s: %v", edit.Op, edit.Path, err)
+	}
+	return nil
+}
+
+type jsonTransaction struct {
+	Name  string `json:"name,omitempty"`
+	Edits []Edit `json:"edits"`
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (tx Transaction) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonTransaction(tx))
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (tx *Transaction) UnmarshalJSON(data []byte) error {
+	var v jsonTransaction
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*tx = Transaction(v)
+	return nil
+}
+
+// snbtString returns the SNBT of tag, or nil for a nil tag.
+func snbtString(tag NbtTag) (*string, error) {
+	if tag == nil {
+		return nil, nil
+	}
+	b, err := MarshalSNBT(tag)
+	if err != nil {
+		return nil, err
+	}
+	s := string(b)
+	return &s, nil
+}
+
+// parseSNBTString parses the SNBT in s, or returns nil for a nil string.
+func parseSNBTString(s *string) (NbtTag, error) {
+	if s == nil {
+		return nil, nil
+	}
+	return ParseSNBT([]byte(*s))
+}
@@ -0,0 +1,407 @@
+package nbtreader
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MarshalSNBT returns the compact, single line SNBT encoding of tag. Other than the output of
+// String, keys and strings are quoted and escaped where needed, so the result is valid SNBT.
+func MarshalSNBT(tag NbtTag) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeSNBT(&buf, tag, false, 0); err != nil {
+		return buf.Bytes(), err
+	}
+	return buf.Bytes(), nil
+}
+
+// MarshalSNBTIndent is like [MarshalSNBT], but every entry of a compound or list is written on a
+// line of its own and indented like the output of String.
+func MarshalSNBTIndent(tag NbtTag) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeSNBT(&buf, tag, true, 0); err != nil {
+		return buf.Bytes(), err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeSNBT writes tag to buf. If indented is set, the entries of compounds and lists are
+// indented by depth+1.
+func writeSNBT(buf *bytes.Buffer, tag NbtTag, indented bool, depth int) error {
+	var entryIndent, endIndent, separator string
+	if indented {
+		entryIndent, endIndent, separator = indent(depth+1), indent(depth), " "
+	}
+	switch t := tag.(type) {
+	case nil:
+		return fmt.Errorf("snbt: cannot marshal nil tag")
+	case String:
+		buf.WriteString(quoteString(string(t)))
+	case ByteArray:
+		writeSNBTArray(buf, "B", t)
+	case IntArray:
+		writeSNBTArray(buf, "I", t)
+	case LongArray:
+		writeSNBTArray(buf, "L", t)
+	case List:
+		buf.WriteByte('[')
+		for i, entry := range t.Elements {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(entryIndent)
+			if err := writeSNBT(buf, entry, indented, depth+1); err != nil {
+				return err
+			}
+		}
+		if len(t.Elements) > 0 {
+			buf.WriteString(endIndent)
+		}
+		buf.WriteByte(']')
+	case Compound:
+		buf.WriteByte('{')
+		for i, comp := range t.getOrdered() {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(entryIndent)
+			if isPlainSNBTKey(string(comp.Key)) {
+				buf.WriteString(string(comp.Key))
+			} else {
+				buf.WriteString(quoteString(string(comp.Key)))
+			}
+			buf.WriteByte(':')
+			buf.WriteString(separator)
+			if err := writeSNBT(buf, comp.Value, indented, depth+1); err != nil {
+				return err
+			}
+		}
+		if len(t) > 0 {
+			buf.WriteString(endIndent)
+		}
+		buf.WriteByte('}')
+	case Byte, Short, Int, Long, Float, Double:
+		buf.WriteString(t.String())
+	case RawTag:
+		decoded, err := t.Decode()
+		if err != nil {
+			return err
+		}
+		return writeSNBT(buf, decoded, indented, depth)
+	default:
+		return fmt.Errorf("snbt: unsupported tag type %s", tag.Type())
+	}
+	return nil
+}
+
+func writeSNBTArray[S ~[]E, E NbtTag](buf *bytes.Buffer, prefix string, s S) {
+	buf.WriteByte('[')
+	buf.WriteString(prefix)
+	buf.WriteByte(';')
+	for i, item := range s {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(item.String())
+	}
+	buf.WriteByte(']')
+}
+
+// isPlainSNBTKey reports whether the compound key k can be written without quotes in SNBT.
+func isPlainSNBTKey(k string) bool {
+	return isPlainKey(strings.ReplaceAll(k, ".", "_"))
+}
+
+// ParseSNBT parses the SNBT encoded data and returns the resulting tag. Keys of compounds may be
+// unquoted, even if they contain spaces, so the output of String can be read as well.
+func ParseSNBT(data []byte) (NbtTag, error) {
+	p := &snbtParser{data: data}
+	tag, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.pos < len(p.data) {
+		return nil, p.errorf("unexpected trailing data")
+	}
+	return tag, nil
+}
+
+type snbtParser struct {
+	data []byte
+	pos  int
+}
+
+func (p *snbtParser) errorf(format string, a ...any) error {
+	return fmt.Errorf("snbt: %s at offset %d", fmt.Sprintf(format, a...), p.pos)
+}
+
+func (p *snbtParser) skipSpace() {
+	for p.pos < len(p.data) {
+		switch p.data[p.pos] {
+		case ' ', '\t', '\n', '\r':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+// peek skips any whitespace and returns the next byte without consuming it, or 0 at the end of
+// the data.
+func (p *snbtParser) peek() byte {
+	p.skipSpace()
+	if p.pos >= len(p.data) {
+		return 0
+	}
+	return p.data[p.pos]
+}
+
+func (p *snbtParser) expect(c byte) error {
+	if p.peek() != c {
+		return p.errorf("expected '%c'", c)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *snbtParser) parseValue() (NbtTag, error) {
+	switch p.peek() {
+	case 0:
+		return nil, p.errorf("unexpected end of data")
+	case '{':
+		return p.parseCompound()
+	case '[':
+		return p.parseListOrArray()
+	case '"', '\'':
+		s, err := p.parseQuoted()
+		return String(s), err
+	default:
+		start := p.pos
+		token := p.parseUnquoted()
+		if token == "" {
+			return nil, p.errorf("unexpected character '%c'", p.data[p.pos])
+		}
+		tag, err := parseSNBTPrimitive(token)
+		if err != nil {
+			p.pos = start
+			return nil, p.errorf("%v", err)
+		}
+		return tag, nil
+	}
+}
+
+func (p *snbtParser) parseCompound() (NbtTag, error) {
+	p.pos++ // '{'
+	t := Compound{}
+	if p.peek() == '}' {
+		p.pos++
+		return t, nil
+	}
+	for {
+		var key string
+		var err error
+		if c := p.peek(); c == '"' || c == '\'' {
+			key, err = p.parseQuoted()
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			start := p.pos
+			for p.pos < len(p.data) && strings.IndexByte(":,{}[]", p.data[p.pos]) < 0 {
+				p.pos++
+			}
+			key = strings.TrimSpace(string(p.data[start:p.pos]))
+			if key == "" {
+				return nil, p.errorf("expected key")
+			}
+		}
+		if err = p.expect(':'); err != nil {
+			return nil, err
+		}
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		t.Set(String(key), value)
+
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			return t, nil
+		default:
+			return nil, p.errorf("expected ',' or '}'")
+		}
+	}
+}
+
+func (p *snbtParser) parseListOrArray() (NbtTag, error) {
+	p.pos++ // '['
+	if p.pos+1 < len(p.data) && p.data[p.pos+1] == ';' {
+		prefix := p.data[p.pos]
+		p.pos += 2
+		switch prefix {
+		case 'B':
+			return parseSNBTArray[ByteArray](p)
+		case 'I':
+			return parseSNBTArray[IntArray](p)
+		case 'L':
+			return parseSNBTArray[LongArray](p)
+		default:
+			p.pos -= 2
+			return nil, p.errorf("unknown array type '%c'", prefix)
+		}
+	}
+
+	t := List{TagType: Tag_End}
+	if p.peek() == ']' {
+		p.pos++
+		return t, nil
+	}
+	for {
+		start := p.pos
+		entry, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		if len(t.Elements) == 0 {
+			t.TagType = entry.Type()
+		} else if entry.Type() != t.TagType {
+			p.pos = start
+			return nil, p.errorf("list element of type %s in list of %s", entry.Type(), t.TagType)
+		}
+		t.Elements = append(t.Elements, entry)
+
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+			p.pos++
+			return t, nil
+		default:
+			return nil, p.errorf("expected ',' or ']'")
+		}
+	}
+}
+
+func parseSNBTArray[S interface {
+	~[]E
+	NbtTag
+}, E Byte | Int | Long](p *snbtParser) (NbtTag, error) {
+	t := S{}
+	if p.peek() == ']' {
+		p.pos++
+		return t, nil
+	}
+	for {
+		start := p.pos
+		entry, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		item, ok := entry.(E)
+		if !ok {
+			p.pos = start
+			return nil, p.errorf("array element of type %s in %s", entry.Type(), t.Type())
+		}
+		t = append(t, item)
+
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+			p.pos++
+			return t, nil
+		default:
+			return nil, p.errorf("expected ',' or ']'")
+		}
+	}
+}
+
+func (p *snbtParser) parseQuoted() (string, error) {
+	quote := p.data[p.pos]
+	p.pos++
+	var b strings.Builder
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		p.pos++
+		switch c {
+		case quote:
+			return b.String(), nil
+		case '\\':
+			if p.pos >= len(p.data) {
+				return "", p.errorf("unterminated string")
+			}
+			b.WriteByte(p.data[p.pos])
+			p.pos++
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *snbtParser) parseUnquoted() string {
+	start := p.pos
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') ||
+			c == '_' || c == '-' || c == '.' || c == '+' {
+			p.pos++
+			continue
+		}
+		break
+	}
+	return string(p.data[start:p.pos])
+}
+
+// parseSNBTPrimitive parses an unquoted SNBT token to a number, boolean or string tag.
+func parseSNBTPrimitive(token string) (NbtTag, error) {
+	switch strings.ToLower(token) {
+	case "true":
+		return Byte(1), nil
+	case "false":
+		return Byte(0), nil
+	}
+
+	last := token[len(token)-1]
+	number := token[:len(token)-1]
+	switch last {
+	case 'b', 'B':
+		if i, err := strconv.ParseInt(number, 10, 8); err == nil {
+			return Byte(i), nil
+		}
+	case 's', 'S':
+		if i, err := strconv.ParseInt(number, 10, 16); err == nil {
+			return Short(i), nil
+		}
+	case 'l', 'L':
+		if i, err := strconv.ParseInt(number, 10, 64); err == nil {
+			return Long(i), nil
+		}
+	case 'f', 'F':
+		if f, err := strconv.ParseFloat(number, 32); err == nil {
+			return Float(f), nil
+		}
+	case 'd', 'D':
+		if f, err := strconv.ParseFloat(number, 64); err == nil {
+			return Double(f), nil
+		}
+	}
+
+	if i, err := strconv.ParseInt(token, 10, 32); err == nil {
+		return Int(i), nil
+	} else if errors.Is(err, strconv.ErrRange) {
+		return nil, fmt.Errorf("int %s out of range", token)
+	}
+	if strings.ContainsAny(token, ".eE") {
+		if f, err := strconv.ParseFloat(token, 64); err == nil {
+			return Double(f), nil
+		}
+	}
+	return String(token), nil
+}
@@ -0,0 +1,625 @@
+package nbtreader
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// The tag types implement the interfaces of the standard library by the functions below:
+//
+//   - fmt.Formatter: %v and %s write compact SNBT, %+v the indented SNBT of String, %#v Go syntax
+//     and %q quoted compact SNBT. Other verbs format the number, string or array as Go values,
+//     e.g. %x of an IntArray.
+//   - encoding.TextMarshaler: compact SNBT, like [MarshalSNBT].
+//   - encoding.BinaryMarshaler and encoding.BinaryUnmarshaler: uncompressed binary NBT of the tag
+//     with its type and an empty name, like an unnamed root tag.
+//   - slog.LogValuer: compounds and lists are logged as groups, numbers and strings as values
+//     and arrays as compact SNBT.
+//
+// [NBT] implements them for its root tag as well, and also database/sql's Scanner and
+// driver.Valuer with its binary data, so it can be stored in a database column.
+
+// Format implements the fmt.Formatter interface.
+func (t Byte) Format(s fmt.State, verb rune) {
+	formatTag(s, verb, t, int8(t))
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (t Byte) MarshalText() ([]byte, error) {
+	return MarshalSNBT(t)
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (t Byte) MarshalBinary() ([]byte, error) {
+	return marshalBinary(t)
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (t *Byte) UnmarshalBinary(data []byte) error {
+	return unmarshalBinary(data, t)
+}
+
+// LogValue implements the slog.LogValuer interface.
+func (t Byte) LogValue() slog.Value {
+	return logValue(t)
+}
+
+// Format implements the fmt.Formatter interface.
+func (t Short) Format(s fmt.State, verb rune) {
+	formatTag(s, verb, t, int16(t))
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (t Short) MarshalText() ([]byte, error) {
+	return MarshalSNBT(t)
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (t Short) MarshalBinary() ([]byte, error) {
+	return marshalBinary(t)
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (t *Short) UnmarshalBinary(data []byte) error {
+	return unmarshalBinary(data, t)
+}
+
+// LogValue implements the slog.LogValuer interface.
+func (t Short) LogValue() slog.Value {
+	return logValue(t)
+}
+
+// Format implements the fmt.Formatter interface.
+func (t Int) Format(s fmt.State, verb rune) {
+	formatTag(s, verb, t, int32(t))
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (t Int) MarshalText() ([]byte, error) {
+	return MarshalSNBT(t)
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (t Int) MarshalBinary() ([]byte, error) {
+	return marshalBinary(t)
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (t *Int) UnmarshalBinary(data []byte) error {
+	return unmarshalBinary(data, t)
+}
+
+// LogValue implements the slog.LogValuer interface.
+func (t Int) LogValue() slog.Value {
+	return logValue(t)
+}
+
+// Format implements the fmt.Formatter interface.
+func (t Long) Format(s fmt.State, verb rune) {
+	formatTag(s, verb, t, int64(t))
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (t Long) MarshalText() ([]byte, error) {
+	return MarshalSNBT(t)
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (t Long) MarshalBinary() ([]byte, error) {
+	return marshalBinary(t)
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (t *Long) UnmarshalBinary(data []byte) error {
+	return unmarshalBinary(data, t)
+}
+
+// LogValue implements the slog.LogValuer interface.
+func (t Long) LogValue() slog.Value {
+	return logValue(t)
+}
+
+// Format implements the fmt.Formatter interface.
+func (t Float) Format(s fmt.State, verb rune) {
+	formatTag(s, verb, t, float32(t))
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (t Float) MarshalText() ([]byte, error) {
+	return MarshalSNBT(t)
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (t Float) MarshalBinary() ([]byte, error) {
+	return marshalBinary(t)
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (t *Float) UnmarshalBinary(data []byte) error {
+	return unmarshalBinary(data, t)
+}
+
+// LogValue implements the slog.LogValuer interface.
+func (t Float) LogValue() slog.Value {
+	return logValue(t)
+}
+
+// Format implements the fmt.Formatter interface.
+func (t Double) Format(s fmt.State, verb rune) {
+	formatTag(s, verb, t, float64(t))
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (t Double) MarshalText() ([]byte, error) {
+	return MarshalSNBT(t)
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (t Double) MarshalBinary() ([]byte, error) {
+	return marshalBinary(t)
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (t *Double) UnmarshalBinary(data []byte) error {
+	return unmarshalBinary(data, t)
+}
+
+// LogValue implements the slog.LogValuer interface.
+func (t Double) LogValue() slog.Value {
+	return logValue(t)
+}
+
+// Format implements the fmt.Formatter interface.
+func (t ByteArray) Format(s fmt.State, verb rune) {
+	formatTag(s, verb, t, []Byte(t))
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (t ByteArray) MarshalText() ([]byte, error) {
+	return MarshalSNBT(t)
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (t ByteArray) MarshalBinary() ([]byte, error) {
+	return marshalBinary(t)
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (t *ByteArray) UnmarshalBinary(data []byte) error {
+	return unmarshalBinary(data, t)
+}
+
+// LogValue implements the slog.LogValuer interface.
+func (t ByteArray) LogValue() slog.Value {
+	return logValue(t)
+}
+
+// Format implements the fmt.Formatter interface.
+func (t String) Format(s fmt.State, verb rune) {
+	formatTag(s, verb, t, string(t))
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (t String) MarshalText() ([]byte, error) {
+	return MarshalSNBT(t)
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (t String) MarshalBinary() ([]byte, error) {
+	return marshalBinary(t)
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (t *String) UnmarshalBinary(data []byte) error {
+	return unmarshalBinary(data, t)
+}
+
+// LogValue implements the slog.LogValuer interface.
+func (t String) LogValue() slog.Value {
+	return logValue(t)
+}
+
+// Format implements the fmt.Formatter interface.
+func (t List) Format(s fmt.State, verb rune) {
+	formatTag(s, verb, t, nil)
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (t List) MarshalText() ([]byte, error) {
+	return MarshalSNBT(t)
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (t List) MarshalBinary() ([]byte, error) {
+	return marshalBinary(t)
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (t *List) UnmarshalBinary(data []byte) error {
+	return unmarshalBinary(data, t)
+}
+
+// LogValue implements the slog.LogValuer interface.
+func (t List) LogValue() slog.Value {
+	return logValue(t)
+}
+
+// Format implements the fmt.Formatter interface.
+func (t Compound) Format(s fmt.State, verb rune) {
+	formatTag(s, verb, t, nil)
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (t Compound) MarshalText() ([]byte, error) {
+	return MarshalSNBT(t)
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (t Compound) MarshalBinary() ([]byte, error) {
+	return marshalBinary(t)
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (t *Compound) UnmarshalBinary(data []byte) error {
+	return unmarshalBinary(data, t)
+}
+
+// LogValue implements the slog.LogValuer interface.
+func (t Compound) LogValue() slog.Value {
+	return logValue(t)
+}
+
+// Format implements the fmt.Formatter interface.
+func (t IntArray) Format(s fmt.State, verb rune) {
+	formatTag(s, verb, t, []Int(t))
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (t IntArray) MarshalText() ([]byte, error) {
+	return MarshalSNBT(t)
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (t IntArray) MarshalBinary() ([]byte, error) {
+	return marshalBinary(t)
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (t *IntArray) UnmarshalBinary(data []byte) error {
+	return unmarshalBinary(data, t)
+}
+
+// LogValue implements the slog.LogValuer interface.
+func (t IntArray) LogValue() slog.Value {
+	return logValue(t)
+}
+
+// Format implements the fmt.Formatter interface.
+func (t LongArray) Format(s fmt.State, verb rune) {
+	formatTag(s, verb, t, []Long(t))
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (t LongArray) MarshalText() ([]byte, error) {
+	return MarshalSNBT(t)
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (t LongArray) MarshalBinary() ([]byte, error) {
+	return marshalBinary(t)
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (t *LongArray) UnmarshalBinary(data []byte) error {
+	return unmarshalBinary(data, t)
+}
+
+// LogValue implements the slog.LogValuer interface.
+func (t LongArray) LogValue() slog.Value {
+	return logValue(t)
+}
+
+// Format implements the fmt.Formatter interface. The tag is decoded for SNBT.
+func (t RawTag) Format(s fmt.State, verb rune) {
+	formatTag(s, verb, t, t.data)
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (t RawTag) MarshalText() ([]byte, error) {
+	return MarshalSNBT(t)
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (t RawTag) MarshalBinary() ([]byte, error) {
+	return marshalBinary(t)
+}
+
+// LogValue implements the slog.LogValuer interface.
+func (t RawTag) LogValue() slog.Value {
+	tag, err := t.Decode()
+	if err != nil {
+		return slog.StringValue(t.String())
+	}
+	return logValue(tag)
+}
+
+// Format implements the fmt.Formatter interface for the root tag.
+func (nbt NBT) Format(s fmt.State, verb rune) {
+	if nbt.root == nil {
+		fmt.Fprintf(s, "%v", nil)
+		return
+	}
+	nbt.root.(fmt.Formatter).Format(s, verb)
+}
+
+// MarshalText implements the encoding.TextMarshaler interface with the compact SNBT of the root
+// tag.
+func (nbt *NBT) MarshalText() ([]byte, error) {
+	return MarshalSNBT(nbt.root)
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface. The data is composed like
+// [NBT.Compose] with the compression of the parsed data.
+func (nbt *NBT) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	c := *nbt
+	c.rw = bufio.NewReadWriter(nil, bufio.NewWriter(&buf))
+	if err := c.Compose(nbt.compression); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface. The data is parsed like by
+// [New], with the options and the writer of nbt. On errors, nbt is left unchanged.
+func (nbt *NBT) UnmarshalBinary(data []byte) error {
+	parsed := &NBT{w: nbt.w, opts: nbt.opts}
+	if err := parsed.read(bytes.NewReader(data)); err != nil {
+		return err
+	}
+	*nbt = *parsed
+	return nil
+}
+
+// LogValue implements the slog.LogValuer interface for the root tag.
+func (nbt *NBT) LogValue() slog.Value {
+	if nbt.root == nil {
+		return slog.AnyValue(nil)
+	}
+	return logValue(nbt.root)
+}
+
+// Scan implements the sql.Scanner interface. The binary data of a column is parsed with
+// [NBT.UnmarshalBinary].
+func (nbt *NBT) Scan(src any) error {
+	switch src := src.(type) {
+	case []byte:
+		return nbt.UnmarshalBinary(src)
+	case string:
+		return nbt.UnmarshalBinary([]byte(src))
+	default:
+		return fmt.Errorf("nbt: cannot scan %T", src)
+	}
+}
+
+// Value implements the driver.Valuer interface with the binary data of [NBT.MarshalBinary].
+func (nbt *NBT) Value() (driver.Value, error) {
+	return nbt.MarshalBinary()
+}
+
+// formatTag formats tag for the verb, see the comment at the top of this file. value is the Go
+// value of a number, string or array, or nil for compounds and lists.
+func formatTag(s fmt.State, verb rune, tag NbtTag, value any) {
+	var str string
+	switch {
+	case verb == 'v' && s.Flag('#'):
+		str = goSyntax(tag)
+	case verb == 'v' && s.Flag('+'):
+		str = tag.String()
+	case verb == 'v' || verb == 's' || verb == 'q':
+		b, err := MarshalSNBT(tag)
+		if err != nil {
+			str = fmt.Sprintf("%%!%c(%v)", verb, err)
+			break
+		}
+		str = string(b)
+		if verb == 'q' {
+			str = strconv.Quote(str)
+		}
+	case value != nil:
+		fmt.Fprintf(s, fmt.FormatString(s, verb), value)
+		return
+	default:
+		fmt.Fprintf(s, "%%!%c(%T)", verb, tag)
+		return
+	}
+	if width, ok := s.Width(); ok {
+		if s.Flag('-') {
+			str += strings.Repeat(" ", max(width-len(str), 0))
+		} else {
+			str = strings.Repeat(" ", max(width-len(str), 0)) + str
+		}
+	}
+	io.WriteString(s, str)
+}
+
+// goSyntax returns tag as a Go expression.
+func goSyntax(tag NbtTag) string {
+	var sb strings.Builder
+	writeGoSyntax(&sb, tag)
+	return sb.String()
+}
+
+func writeGoSyntax(sb *strings.Builder, tag NbtTag) {
+	switch t := tag.(type) {
+	case nil:
+		sb.WriteString("nil")
+	case Byte, Short, Int, Long:
+		fmt.Fprintf(sb, "%T(%d)", t, t)
+	case Float:
+		fmt.Fprintf(sb, "nbtreader.Float(%s)", goFloat(float64(t), 32))
+	case Double:
+		fmt.Fprintf(sb, "nbtreader.Double(%s)", goFloat(float64(t), 64))
+	case String:
+		fmt.Fprintf(sb, "nbtreader.String(%s)", strconv.Quote(string(t)))
+	case ByteArray:
+		writeGoArray(sb, "ByteArray", t)
+	case IntArray:
+		writeGoArray(sb, "IntArray", t)
+	case LongArray:
+		writeGoArray(sb, "LongArray", t)
+	case List:
+		fmt.Fprintf(sb, "nbtreader.List{TagType: %s, Elements: []nbtreader.NbtTag{", goTagType(t.TagType))
+		for i, entry := range t.Elements {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			writeGoSyntax(sb, entry)
+		}
+		sb.WriteString("}}")
+	case Compound:
+		sb.WriteString("nbtreader.Compound{")
+		for i, comp := range t.getOrdered() {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			fmt.Fprintf(sb, "%s: {Index: %d, Value: ", strconv.Quote(string(comp.Key)), i)
+			writeGoSyntax(sb, comp.Value)
+			sb.WriteByte('}')
+		}
+		sb.WriteByte('}')
+	case RawTag:
+		fmt.Fprintf(sb, "nbtreader.NewRawTag(%s, %#v)", goTagType(t.tagType), t.data)
+	default:
+		fmt.Fprintf(sb, "%#v", tag)
+	}
+}
+
+func writeGoArray[T Byte | Int | Long](sb *strings.Builder, name string, items []T) {
+	fmt.Fprintf(sb, "nbtreader.%s{", name)
+	for i, item := range items {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		fmt.Fprintf(sb, "%d", item)
+	}
+	sb.WriteByte('}')
+}
+
+// goFloat returns f as a Go expression, where NaN and infinities are calls of the math package.
+func goFloat(f float64, bitSize int) string {
+	switch {
+	case math.IsNaN(f):
+		return "math.NaN()"
+	case math.IsInf(f, 1):
+		return "math.Inf(1)"
+	case math.IsInf(f, -1):
+		return "math.Inf(-1)"
+	}
+	return strconv.FormatFloat(f, 'g', -1, bitSize)
+}
+
+// goTagType returns the name of the constant of t, e.g. "nbtreader.Tag_Byte_Array".
+func goTagType(t TagType) string {
+	name, ok := tagTypeNames[t]
+	if !ok {
+		return fmt.Sprintf("nbtreader.TagType(%d)", byte(t))
+	}
+	parts := strings.Split(name, "_")
+	for i, part := range parts {
+		parts[i] = strings.ToUpper(part[:1]) + part[1:]
+	}
+	return "nbtreader.Tag_" + strings.Join(parts, "_")
+}
+
+// marshalBinary returns the uncompressed binary NBT of tag with its type and an empty name.
+func marshalBinary(tag NbtTag) ([]byte, error) {
+	if err := Validate(tag); err != nil {
+		return nil, fmt.Errorf("nbt: invalid tree: %w", err)
+	}
+	var buf bytes.Buffer
+	e := &encoder{w: &buf}
+	if err := pushByte(e, tag.Type()); err != nil {
+		return nil, err
+	}
+	if err := pushString(e, ""); err != nil {
+		return nil, err
+	}
+	if err := tag.compose(e); err != nil {
+		return nil, fmt.Errorf("nbt: %s: %w", e.path.Display(), err)
+	}
+	return buf.Bytes(), nil
+}
+
+// unmarshalBinary parses the binary NBT of marshalBinary to t. The type in the data must be the
+// type of t.
+func unmarshalBinary[T NbtTag](data []byte, t *T) error {
+	tagType := (*t).Type()
+	d := newDecoder(bytes.NewReader(data), options{})
+	found, err := popType(d)
+	if err != nil {
+		return fmt.Errorf("nbt: %w", noEOF(err))
+	}
+	if found != tagType {
+		return d.error(found, fmt.Errorf("expected %s, found %s", tagType, found))
+	}
+	if _, err = popString(d); err != nil {
+		return d.error(tagType, fmt.Errorf("reading name: %w", noEOF(err)))
+	}
+	tag, err := parseType(d, tagType)
+	if err != nil {
+		return err
+	}
+	if err = d.checkTrailing(tagType); err != nil {
+		return err
+	}
+	*t = tag.(T)
+	return nil
+}
+
+// logValue returns the slog.Value of tag.
+func logValue(tag NbtTag) slog.Value {
+	switch t := tag.(type) {
+	case Byte:
+		return slog.Int64Value(int64(t))
+	case Short:
+		return slog.Int64Value(int64(t))
+	case Int:
+		return slog.Int64Value(int64(t))
+	case Long:
+		return slog.Int64Value(int64(t))
+	case Float:
+		return slog.Float64Value(float64(t))
+	case Double:
+		return slog.Float64Value(float64(t))
+	case String:
+		return slog.StringValue(string(t))
+	case Compound:
+		attrs := make([]slog.Attr, 0, len(t))
+		for _, comp := range t.getOrdered() {
+			attrs = append(attrs, slog.Attr{Key: string(comp.Key), Value: logValue(comp.Value)})
+		}
+		return slog.GroupValue(attrs...)
+	case List:
+		attrs := make([]slog.Attr, len(t.Elements))
+		for i, entry := range t.Elements {
+			attrs[i] = slog.Attr{Key: strconv.Itoa(i), Value: logValue(entry)}
+		}
+		return slog.GroupValue(attrs...)
+	case nil:
+		return slog.AnyValue(nil)
+	default:
+		b, err := MarshalSNBT(tag)
+		if err != nil {
+			return slog.StringValue(err.Error())
+		}
+		return slog.StringValue(string(b))
+	}
+}
@@ -0,0 +1,236 @@
+package nbtreader
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Encoder writes NBT data tag by tag, without building the tree in memory. It checks, that
+// compounds and lists are nested correctly and that lists get the announced number of elements of
+// their type. Like [Decoder], a root tag is a compound or list, that is started with a name and
+// ended with [Encoder.End].
+//
+// Inside compounds, every tag is written with its name. Elements of lists have no name, so name
+// must be empty there.
+type Encoder struct {
+	w   *bufio.Writer
+	cw  io.WriteCloser
+	e   *encoder
+	err error
+
+	// open holds the compounds and lists, that are not ended yet
+	open []openTag
+}
+
+// NewEncoder returns an Encoder, that writes to w, compressed with c. The level of gzip and zlib
+// compression is set with the option [CompressionLevel]. The Encoder must be closed to complete
+// the data.
+func NewEncoder(w io.Writer, c Compression, opts ...Option) (*Encoder, error) {
+	nbt := &NBT{}
+	for _, opt := range opts {
+		opt(&nbt.opts)
+	}
+	cw, err := nbt.compressor(w, c)
+	if err != nil {
+		return nil, fmt.Errorf("nbt: %v", err)
+	}
+	bw := bufio.NewWriter(cw)
+	return &Encoder{w: bw, cw: cw, e: newEncoder(bw, nbt.opts)}, nil
+}
+
+// BeginCompound starts a compound. Its entries are written until the matching [Encoder.End].
+func (enc *Encoder) BeginCompound(name String) error {
+	return enc.do(func() error {
+		if err := enc.header(Tag_Compound, name); err != nil {
+			return err
+		}
+		enc.open = append(enc.open, openTag{tagType: Tag_Compound})
+		return nil
+	})
+}
+
+// BeginList starts a list of n elements of elemType. Exactly n elements must be written before the
+// matching [Encoder.End].
+func (enc *Encoder) BeginList(name String, elemType TagType, n int) error {
+	return enc.do(func() error {
+		switch {
+		case elemType > Tag_Long_Array:
+			return fmt.Errorf("unknown type 0x%02x", byte(elemType))
+		case n < 0 || n > MaxArrayLength:
+			return fmt.Errorf("invalid list length %d", n)
+		case elemType == Tag_End && n > 0:
+			return fmt.Errorf("list cannot be of type TAG_END")
+		}
+		if err := enc.header(Tag_List, name); err != nil {
+			return err
+		}
+		if err := pushByte(enc.e, elemType); err != nil {
+			return err
+		}
+		if err := pushInt(enc.e, n); err != nil {
+			return err
+		}
+		enc.open = append(enc.open, openTag{tagType: Tag_List, elemType: elemType, length: n})
+		return nil
+	})
+}
+
+// End ends the innermost compound or list.
+func (enc *Encoder) End() error {
+	return enc.do(func() error {
+		if len(enc.open) == 0 {
+			return errors.New("no compound or list to end")
+		}
+		top := enc.open[len(enc.open)-1]
+		if top.tagType == Tag_List && top.next != top.length {
+			return fmt.Errorf("list has %d of %d elements", top.next, top.length)
+		}
+		if top.tagType == Tag_Compound {
+			if err := pushByte(enc.e, Tag_End); err != nil {
+				return err
+			}
+		}
+		enc.open = enc.open[:len(enc.open)-1]
+		enc.pop()
+		return nil
+	})
+}
+
+// WriteTag writes tag with the given name. It may be a whole tree, which is checked with
+// [Validate] first. Bytes are written with WriteTag(name, Byte(v)).
+func (enc *Encoder) WriteTag(name String, tag NbtTag) error {
+	return enc.do(func() error {
+		if tag == nil {
+			return errors.New("cannot write nil tag")
+		}
+		if err := Validate(tag); err != nil {
+			return err
+		}
+		if err := enc.header(tag.Type(), name); err != nil {
+			return err
+		}
+		if err := tag.compose(enc.e); err != nil {
+			return err
+		}
+		enc.pop()
+		return nil
+	})
+}
+
+// WriteShort writes a short with the given name.
+func (enc *Encoder) WriteShort(name String, v Short) error {
+	return enc.WriteTag(name, v)
+}
+
+// WriteInt writes an int with the given name.
+func (enc *Encoder) WriteInt(name String, v Int) error {
+	return enc.WriteTag(name, v)
+}
+
+// WriteLong writes a long with the given name.
+func (enc *Encoder) WriteLong(name String, v Long) error {
+	return enc.WriteTag(name, v)
+}
+
+// WriteFloat writes a float with the given name.
+func (enc *Encoder) WriteFloat(name String, v Float) error {
+	return enc.WriteTag(name, v)
+}
+
+// WriteDouble writes a double with the given name.
+func (enc *Encoder) WriteDouble(name String, v Double) error {
+	return enc.WriteTag(name, v)
+}
+
+// WriteString writes a string with the given name.
+func (enc *Encoder) WriteString(name String, v String) error {
+	return enc.WriteTag(name, v)
+}
+
+// WriteByteArray writes a byte array with the given name.
+func (enc *Encoder) WriteByteArray(name String, v ByteArray) error {
+	return enc.WriteTag(name, v)
+}
+
+// WriteIntArray writes an int array with the given name.
+func (enc *Encoder) WriteIntArray(name String, v IntArray) error {
+	return enc.WriteTag(name, v)
+}
+
+// WriteLongArray writes a long array with the given name.
+func (enc *Encoder) WriteLongArray(name String, v LongArray) error {
+	return enc.WriteTag(name, v)
+}
+
+// Close checks, that all compounds and lists are ended, and completes the compressed data. It does
+// not close the underlying writer.
+func (enc *Encoder) Close() error {
+	return enc.do(func() error {
+		if len(enc.open) > 0 {
+			return fmt.Errorf("%d compounds or lists are not ended", len(enc.open))
+		}
+		if err := enc.w.Flush(); err != nil {
+			return err
+		}
+		return enc.cw.Close()
+	})
+}
+
+// do runs f, unless a previous call failed. Errors are kept, so the data is not continued after
+// them.
+func (enc *Encoder) do(f func() error) error {
+	if enc.err != nil {
+		return enc.err
+	}
+	if err := f(); err != nil {
+		enc.err = fmt.Errorf("nbt: %s: %w", enc.e.path.Display(), err)
+	}
+	return enc.err
+}
+
+// header writes the type and name of a tag of tagType, as far as it is needed at the current
+// position, and adds the tag to the path.
+func (enc *Encoder) header(tagType TagType, name String) error {
+	if len(enc.open) == 0 {
+		if tagType != Tag_Compound && tagType != Tag_List {
+			return fmt.Errorf("invalid root tag: %s", tagType)
+		}
+		if err := pushByte(enc.e, tagType); err != nil {
+			return err
+		}
+		return pushString(enc.e, name)
+	}
+
+	top := &enc.open[len(enc.open)-1]
+	if top.tagType == Tag_List {
+		switch {
+		case name != "":
+			return fmt.Errorf("list element cannot have a name: %q", name)
+		case tagType != top.elemType:
+			return fmt.Errorf("list of %s cannot have an element of %s", top.elemType, tagType)
+		case top.next == top.length:
+			return fmt.Errorf("list has more than %d elements", top.length)
+		}
+		enc.e.path = append(enc.e.path, PathElement{Index: top.next, IsIndex: true})
+		top.next++
+		return nil
+	}
+
+	if err := pushByte(enc.e, tagType); err != nil {
+		return err
+	}
+	if err := pushString(enc.e, name); err != nil {
+		return err
+	}
+	enc.e.path = append(enc.e.path, PathElement{Key: name})
+	return nil
+}
+
+// pop removes the last written tag from the path. Root tags are not part of the path.
+func (enc *Encoder) pop() {
+	if len(enc.e.path) > 0 && len(enc.e.path) >= len(enc.open) {
+		enc.e.path = enc.e.path[:len(enc.e.path)-1]
+	}
+}
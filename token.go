@@ -0,0 +1,385 @@
+package nbtreader
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// TokenKind is the kind of a [Token].
+type TokenKind byte
+
+const (
+	// TokenBeginCompound starts a compound. Its entries follow until the matching TokenEnd.
+	TokenBeginCompound TokenKind = iota + 1
+	// TokenBeginList starts a list of Token.Len elements of Token.ElemType, followed by the
+	// matching TokenEnd.
+	TokenBeginList
+	// TokenValue is a number or string in Token.Value.
+	TokenValue
+	// TokenArray is the header of a byte, int or long array of Token.Len elements. The elements
+	// are read with [Decoder.ReadBytes], [Decoder.ReadInts] and [Decoder.ReadLongs], or skipped by
+	// the next call of [Decoder.Token].
+	TokenArray
+	// TokenEnd ends the innermost compound or list.
+	TokenEnd
+)
+
+var tokenKindNames = map[TokenKind]string{
+	TokenBeginCompound: "BeginCompound",
+	TokenBeginList:     "BeginList",
+	TokenValue:         "Value",
+	TokenArray:         "Array",
+	TokenEnd:           "End",
+}
+
+// String implements the fmt.Stringer interface.
+func (k TokenKind) String() string {
+	if name, ok := tokenKindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown token kind %d", byte(k))
+}
+
+// Token is a single event of a [Decoder].
+type Token struct {
+	Kind TokenKind
+	// Name is the key of the tag in its compound, or the name of a root tag. Elements of lists have
+	// no name.
+	Name String
+	// Type is the type of the tag, that is started, ended or read.
+	Type TagType
+	// ElemType is the element type of a list.
+	ElemType TagType
+	// Len is the number of elements of a list or an array.
+	Len int
+	// Value is the value of a number or string.
+	Value NbtTag
+}
+
+// Decoder reads NBT data as a stream of tokens, without building the tree. Only the compounds and
+// lists, that are currently open, are kept, so files of any size are read in constant memory.
+type Decoder struct {
+	// src holds the decompressed data and the options
+	src *NBT
+	d   *decoder
+	err error
+
+	// open holds the compounds and lists, that are not ended yet
+	open []openTag
+	// leaf is set, if the last element of the path belongs to the last token, but not to an open
+	// tag
+	leaf bool
+
+	// arrayType and arrayLeft describe the array of the last token with the elements not read yet.
+	// arrayType is Tag_End, if the last token is no array.
+	arrayType TagType
+	arrayLeft int
+	buf       []byte
+}
+
+// openTag is a compound or list, that was started by a token, but not ended yet.
+type openTag struct {
+	tagType TagType
+	// elemType, length and next are the element type, length and the index of the next element of
+	// a list
+	elemType TagType
+	length   int
+	next     int
+}
+
+// NewDecoder returns a Decoder, that reads tokens from r. Compressed data is decompressed. The
+// limits of [WithLimits] apply to the depth, the length of lists and arrays and the decompressed
+// size, but not the size of the tree, as it is not kept.
+func NewDecoder(r io.Reader, opts ...Option) *Decoder {
+	src := &NBT{rw: bufio.NewReadWriter(bufio.NewReader(r), nil)}
+	for _, opt := range opts {
+		opt(&src.opts)
+	}
+	return &Decoder{src: src}
+}
+
+// Token returns the next token. A root tag starts with the token of its compound or list, where
+// Name is the root name, and ends with the matching TokenEnd. Token returns io.EOF, if the data
+// ends after a root tag, so a sequence of root tags is read one after another. After any error,
+// Token returns the same error again.
+func (dec *Decoder) Token() (Token, error) {
+	if dec.err != nil {
+		return Token{}, dec.err
+	}
+	tok, err := dec.next()
+	if err != nil {
+		dec.err = err
+	}
+	return tok, err
+}
+
+func (dec *Decoder) next() (Token, error) {
+	if dec.d == nil {
+		if err := dec.src.decompress(); err != nil {
+			return Token{}, fmt.Errorf("nbt: %v", err)
+		}
+		dec.d = newDecoder(dec.src.rw, dec.src.opts)
+		// nothing is kept, so the size of the tree is not limited
+		dec.d.limits.MaxBytes = 0
+	}
+	d := dec.d
+	if err := dec.settle(); err != nil {
+		return Token{}, err
+	}
+
+	if len(dec.open) == 0 {
+		return dec.root()
+	}
+	top := &dec.open[len(dec.open)-1]
+	if top.tagType == Tag_List {
+		if top.next == top.length {
+			return dec.end(), nil
+		}
+		d.path = append(d.path, PathElement{Index: top.next, IsIndex: true})
+		top.next++
+		return dec.tag(top.elemType, "")
+	}
+
+	t, err := popByte(d)
+	if err != nil {
+		return Token{}, d.error(Tag_Compound, err)
+	}
+	if TagType(t) == Tag_End {
+		return dec.end(), nil
+	}
+	name, err := popKey(d)
+	if err != nil {
+		return Token{}, d.error(Tag_Compound, fmt.Errorf("reading key: %w", noEOF(err)))
+	}
+	d.path = append(d.path, PathElement{Key: name})
+	return dec.tag(TagType(t), name)
+}
+
+// settle skips the rest of the array of the last token and removes the path of the last token, if
+// it does not belong to an open tag.
+func (dec *Decoder) settle() error {
+	d := dec.d
+	if dec.arrayLeft > 0 {
+		size := int64(dec.arrayLeft) * arrayElemSize(dec.arrayType)
+		if _, err := io.CopyN(io.Discard, d, size); err != nil {
+			return d.error(dec.arrayType, err)
+		}
+		dec.arrayLeft = 0
+	}
+	dec.arrayType = Tag_End
+	if dec.leaf {
+		d.path = d.path[:len(d.path)-1]
+		dec.leaf = false
+	}
+	return nil
+}
+
+// root reads the type and name of the next root tag.
+func (dec *Decoder) root() (Token, error) {
+	d := dec.d
+	if _, err := dec.src.rw.Peek(1); err == io.EOF {
+		return Token{}, io.EOF
+	}
+	rootType, err := popType(d)
+	if err != nil {
+		return Token{}, fmt.Errorf("nbt: %w", err)
+	}
+	switch rootType {
+	case Tag_Compound, Tag_List:
+	default:
+		return Token{}, d.error(rootType, fmt.Errorf("found invalid root tag: %s", rootType))
+	}
+	name, err := popString(d)
+	if err != nil {
+		return Token{}, d.error(rootType, fmt.Errorf("reading root name: %w", noEOF(err)))
+	}
+	return dec.tag(rootType, name)
+}
+
+// tag reads the tag of the given type, after its name and path were read.
+func (dec *Decoder) tag(tagType TagType, name String) (Token, error) {
+	d := dec.d
+	tok := Token{Name: name, Type: tagType}
+	switch tagType {
+	case Tag_Compound:
+		if err := d.checkDepth(); err != nil {
+			return tok, d.error(tagType, err)
+		}
+		tok.Kind = TokenBeginCompound
+		dec.open = append(dec.open, openTag{tagType: tagType})
+	case Tag_List:
+		if err := d.checkDepth(); err != nil {
+			return tok, d.error(tagType, err)
+		}
+		elemType, err := popByte(d)
+		if err != nil {
+			return tok, d.error(tagType, err)
+		}
+		length, err := popInt(d)
+		if err != nil {
+			return tok, d.error(tagType, err)
+		}
+		if err = d.checkLength(length); err != nil {
+			return tok, d.error(tagType, err)
+		}
+		if TagType(elemType) == Tag_End && length > 0 {
+			return tok, d.error(tagType, fmt.Errorf("list cannot be of type TAG_END"))
+		}
+		tok.Kind, tok.ElemType, tok.Len = TokenBeginList, TagType(elemType), int(length)
+		dec.open = append(dec.open, openTag{tagType: tagType, elemType: TagType(elemType), length: int(length)})
+	case Tag_Byte_Array, Tag_Int_Array, Tag_Long_Array:
+		length, err := popInt(d)
+		if err != nil {
+			return tok, d.error(tagType, err)
+		}
+		if err = d.checkLength(length); err != nil {
+			return tok, d.error(tagType, err)
+		}
+		tok.Kind, tok.Len = TokenArray, int(length)
+		dec.arrayType, dec.arrayLeft = tagType, int(length)
+		dec.leaf = true
+	default:
+		value, err := parseType(d, tagType)
+		if err != nil {
+			return tok, err
+		}
+		tok.Kind, tok.Value = TokenValue, value
+		dec.leaf = true
+	}
+	return tok, nil
+}
+
+// end ends the innermost open compound or list.
+func (dec *Decoder) end() Token {
+	top := dec.open[len(dec.open)-1]
+	dec.open = dec.open[:len(dec.open)-1]
+	if len(dec.open) > 0 {
+		// the path of the ended tag is removed with the next token
+		dec.leaf = true
+	}
+	return Token{Kind: TokenEnd, Type: top.tagType}
+}
+
+// Skip skips the rest of the innermost open compound or list, including its TokenEnd. The skipped
+// tags are read over by their length, without decoding them.
+func (dec *Decoder) Skip() error {
+	if dec.err != nil {
+		return dec.err
+	}
+	if len(dec.open) == 0 {
+		return errors.New("nbt: no compound or list to skip")
+	}
+	if err := dec.settle(); err != nil {
+		dec.err = err
+		return err
+	}
+	top := &dec.open[len(dec.open)-1]
+	s := dec.d.skipper(nil)
+	var err error
+	if top.tagType == Tag_List {
+		err = s.elements(top.elemType, top.length-top.next, 1)
+		top.next = top.length
+	} else {
+		err = s.entries(1)
+	}
+	if err != nil {
+		dec.err = dec.d.error(top.tagType, err)
+		return dec.err
+	}
+	dec.end()
+	return nil
+}
+
+// Depth returns the number of compounds and lists, that are open.
+func (dec *Decoder) Depth() int {
+	return len(dec.open)
+}
+
+// Path returns the path of the tag of the last token. For TokenEnd, it is the path of the ended
+// compound or list.
+func (dec *Decoder) Path() Path {
+	if dec.d == nil {
+		return nil
+	}
+	return slices.Clone(dec.d.path)
+}
+
+// Offset returns the number of decompressed bytes read so far.
+func (dec *Decoder) Offset() int64 {
+	if dec.d == nil {
+		return 0
+	}
+	return dec.d.offset
+}
+
+// ReadBytes reads the next elements of the byte array of the last token into p. It returns the
+// number of elements read, and io.EOF after all elements were read.
+func (dec *Decoder) ReadBytes(p []Byte) (int, error) {
+	b, err := dec.readArray(Tag_Byte_Array, len(p))
+	for i := range b {
+		p[i] = Byte(b[i])
+	}
+	return len(b), err
+}
+
+// ReadInts reads the next elements of the int array of the last token into p. It returns the
+// number of elements read, and io.EOF after all elements were read.
+func (dec *Decoder) ReadInts(p []Int) (int, error) {
+	b, err := dec.readArray(Tag_Int_Array, len(p))
+	n := len(b) / 4
+	for i := 0; i < n; i++ {
+		p[i] = Int(dec.d.order.Uint32(b[4*i:]))
+	}
+	return n, err
+}
+
+// ReadLongs reads the next elements of the long array of the last token into p. It returns the
+// number of elements read, and io.EOF after all elements were read.
+func (dec *Decoder) ReadLongs(p []Long) (int, error) {
+	b, err := dec.readArray(Tag_Long_Array, len(p))
+	n := len(b) / 8
+	for i := 0; i < n; i++ {
+		p[i] = Long(dec.d.order.Uint64(b[8*i:]))
+	}
+	return n, err
+}
+
+// readArray reads up to n elements of the current array of type tagType and returns their data.
+func (dec *Decoder) readArray(tagType TagType, n int) ([]byte, error) {
+	if dec.err != nil {
+		return nil, dec.err
+	}
+	if dec.arrayType != tagType {
+		return nil, fmt.Errorf("nbt: last token is not a %s", tagType)
+	}
+	if dec.arrayLeft == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, dec.arrayLeft)
+	size := n * int(arrayElemSize(tagType))
+	if cap(dec.buf) < size {
+		dec.buf = make([]byte, size)
+	}
+	b := dec.buf[:size]
+	if _, err := io.ReadFull(dec.d, b); err != nil {
+		dec.err = dec.d.error(tagType, err)
+		return nil, dec.err
+	}
+	dec.arrayLeft -= n
+	return b, nil
+}
+
+// arrayElemSize returns the size of an element of an array of type t in bytes.
+func arrayElemSize(t TagType) int64 {
+	switch t {
+	case Tag_Int_Array:
+		return 4
+	case Tag_Long_Array:
+		return 8
+	default:
+		return 1
+	}
+}
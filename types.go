@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"slices"
+	"strconv"
 	"strings"
 )
 
@@ -60,6 +62,44 @@ func (t TagType) String() string {
 	}
 }
 
+// tagTypeNames are the names of the tag types used in text formats like schemas.
+var tagTypeNames = map[TagType]string{
+	Tag_End:        "end",
+	Tag_Byte:       "byte",
+	Tag_Short:      "short",
+	Tag_Int:        "int",
+	Tag_Long:       "long",
+	Tag_Float:      "float",
+	Tag_Double:     "double",
+	Tag_Byte_Array: "byte_array",
+	Tag_String:     "string",
+	Tag_List:       "list",
+	Tag_Compound:   "compound",
+	Tag_Int_Array:  "int_array",
+	Tag_Long_Array: "long_array",
+}
+
+// MarshalText implements the encoding.TextMarshaler interface. The type is encoded by its lower
+// case name, e.g. "byte_array".
+func (t TagType) MarshalText() ([]byte, error) {
+	name, ok := tagTypeNames[t]
+	if !ok {
+		return nil, fmt.Errorf("unknown type 0x%02x", byte(t))
+	}
+	return []byte(name), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (t *TagType) UnmarshalText(text []byte) error {
+	for tagType, name := range tagTypeNames {
+		if strings.EqualFold(name, string(text)) {
+			*t = tagType
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown type '%s'", text)
+}
+
 func (t TagType) Annotation() TypeAnnotation {
 	switch t {
 	case Tag_Byte:
@@ -94,8 +134,8 @@ func (t TagType) Annotation() TypeAnnotation {
 
 func popType(r io.Reader) (TagType, error) {
 	var ttype [1]byte
-	if _, err := r.Read(ttype[:]); err != nil {
-		return 0x00, fmt.Errorf("pop type: %v", err)
+	if _, err := io.ReadFull(r, ttype[:]); err != nil {
+		return 0x00, fmt.Errorf("pop type: %w", err)
 	}
 
 	t := TagType(ttype[0])
@@ -105,26 +145,19 @@ func popType(r io.Reader) (TagType, error) {
 type NbtTag interface {
 	String() string
 	Type() TagType
-	parse(io.Reader) (NbtTag, error)
-	compose(io.Writer) error
+	parse(*decoder) (NbtTag, error)
+	compose(*encoder) error
 }
 
-var indention int = 0
-
-func indent() string {
-	return "\n" + strings.Repeat("  ", indention)
-}
-func indentIncr() {
-	indention = indention + 1
-}
-func indentDecr() {
-	indention = indention - 1
+// indent returns a line break with the indentation of the given depth.
+func indent(depth int) string {
+	return "\n" + strings.Repeat("  ", depth)
 }
 
 type Byte int8
 
 func (t Byte) String() string {
-	return fmt.Sprintf("%db", t)
+	return strconv.Itoa(int(t)) + "b"
 }
 
 func (t Byte) Type() TagType {
@@ -138,7 +171,7 @@ func (t Byte) MarshalJSON() ([]byte, error) {
 type Short int16
 
 func (t Short) String() string {
-	return fmt.Sprintf("%ds", t)
+	return strconv.Itoa(int(t)) + "s"
 }
 
 func (t Short) Type() TagType {
@@ -152,7 +185,7 @@ func (t Short) MarshalJSON() ([]byte, error) {
 type Int int32
 
 func (t Int) String() string {
-	return fmt.Sprintf("%d", t)
+	return strconv.Itoa(int(t))
 }
 
 func (t Int) Type() TagType {
@@ -166,7 +199,7 @@ func (t Int) MarshalJSON() ([]byte, error) {
 type Long int64
 
 func (t Long) String() string {
-	return fmt.Sprintf("%dl", t)
+	return strconv.FormatInt(int64(t), 10) + "l"
 }
 
 func (t Long) Type() TagType {
@@ -180,7 +213,7 @@ func (t Long) MarshalJSON() ([]byte, error) {
 type Float float32
 
 func (t Float) String() string {
-	return fmt.Sprintf("%gf", t)
+	return strconv.FormatFloat(float64(t), 'g', -1, 32) + "f"
 }
 
 func (t Float) Type() TagType {
@@ -194,7 +227,7 @@ func (t Float) MarshalJSON() ([]byte, error) {
 type Double float64
 
 func (t Double) String() string {
-	return fmt.Sprintf("%gd", t)
+	return strconv.FormatFloat(float64(t), 'g', -1, 64) + "d"
 }
 
 func (t Double) Type() TagType {
@@ -208,15 +241,7 @@ func (t Double) MarshalJSON() ([]byte, error) {
 type ByteArray []Byte
 
 func (t ByteArray) String() string {
-	var itemsString string
-	for i, item := range t {
-		if i == 0 {
-			itemsString = fmt.Sprint(item)
-		} else {
-			itemsString = itemsString + ", " + fmt.Sprint(item)
-		}
-	}
-	return fmt.Sprintf("[B; %s]", itemsString)
+	return arrayString("B", t)
 }
 
 func (t ByteArray) Type() TagType {
@@ -237,8 +262,10 @@ func (t String) Type() TagType {
 	return Tag_String
 }
 
-func (t String) Len() Short {
-	return Short(len(t))
+// Len returns the length of the string in bytes, when encoded in Modified UTF-8 like in NBT data.
+// It may exceed [MaxStringLength], which is invalid in NBT data.
+func (t String) Len() int {
+	return mutf8Len(string(t))
 }
 
 func (t String) MarshalJSON() ([]byte, error) {
@@ -251,32 +278,38 @@ type List struct {
 }
 
 func (t List) String() string {
-	var entriesString, indentation, indentationEnd string
+	var sb strings.Builder
+	writeString(&sb, t, 0)
+	return sb.String()
+}
+
+func (t List) writeString(sb *strings.Builder, depth int) {
+	var indentation, indentationEnd string
 
 	if t.Type() == Tag_List || t.Type() == Tag_Compound {
-		indentationEnd = indent()
-		indentIncr()
-		indentation = indent()
-		defer indentDecr()
+		indentationEnd = indent(depth)
+		indentation = indent(depth + 1)
 	}
 
+	sb.WriteByte('[')
 	for i, entry := range t.Elements {
 		if i != 0 {
-			entriesString += ","
+			sb.WriteByte(',')
 			if indentation == "" {
-				entriesString += " "
+				sb.WriteByte(' ')
 			}
 		}
-		entriesString += indentation
+		sb.WriteString(indentation)
 
 		compound, isCompound := entry.(Compound)
 		if compoundEntry, emptyKey := compound[""]; isCompound && emptyKey && len(compound) == 1 {
-			entriesString += compoundEntry.Value.String()
+			writeString(sb, compoundEntry.Value, depth+1)
 		} else {
-			entriesString += entry.String()
+			writeString(sb, entry, depth+1)
 		}
 	}
-	return fmt.Sprintf("[%s%s]", entriesString, indentationEnd)
+	sb.WriteString(indentationEnd)
+	sb.WriteByte(']')
 }
 
 func (t List) Type() TagType {
@@ -297,28 +330,48 @@ type orderedCompound struct {
 }
 
 func (t Compound) String() string {
-	var childsString string
-	i := 0
-	for _, comp := range t.getOrdered() {
-		if i == 0 {
-			indentIncr()
-		} else {
-			childsString = childsString + ","
-		}
-		childsString += fmt.Sprintf("%s%s: %s", indent(), string(comp.Key), comp.Value.String())
-		if i == len(t)-1 {
-			indentDecr()
-			childsString = childsString + indent()
+	var sb strings.Builder
+	writeString(&sb, t, 0)
+	return sb.String()
+}
+
+func (t Compound) writeString(sb *strings.Builder, depth int) {
+	sb.WriteByte('{')
+	for i, comp := range t.getOrdered() {
+		if i != 0 {
+			sb.WriteByte(',')
 		}
-		i++
+		sb.WriteString(indent(depth + 1))
+		sb.WriteString(string(comp.Key))
+		sb.WriteString(": ")
+		writeString(sb, comp.Value, depth+1)
+	}
+	if len(t) > 0 {
+		sb.WriteString(indent(depth))
 	}
-	return fmt.Sprintf("{%s}", childsString)
+	sb.WriteByte('}')
 }
 
 func (t Compound) Type() TagType {
 	return Tag_Compound
 }
 
+// checkIndices reports an error, unless the indices of the entries are the positions 0 to n-1,
+// each used once. getOrdered and Keys must only be called on such compounds.
+func (t Compound) checkIndices() error {
+	used := make([]bool, len(t))
+	for k, v := range t {
+		if v.Index < 0 || v.Index >= len(t) {
+			return fmt.Errorf("entry %s has index %d out of range [0, %d)", quoteString(string(k)), v.Index, len(t))
+		}
+		if used[v.Index] {
+			return fmt.Errorf("index %d is used by more than one entry", v.Index)
+		}
+		used[v.Index] = true
+	}
+	return nil
+}
+
 func (t Compound) getOrdered() []orderedCompound {
 	ordered := make([]orderedCompound, len(t))
 	for k, v := range t {
@@ -327,6 +380,48 @@ func (t Compound) getOrdered() []orderedCompound {
 	return ordered
 }
 
+// Keys returns the keys of the compound in their order.
+func (t Compound) Keys() []String {
+	keys := make([]String, len(t))
+	for k, v := range t {
+		keys[v.Index] = k
+	}
+	return keys
+}
+
+// Get returns the value stored under key and whether it exists.
+func (t Compound) Get(key String) (NbtTag, bool) {
+	entry, ok := t[key]
+	return entry.Value, ok
+}
+
+// Set stores value under key. An existing key keeps its position, a new key is appended to the end
+// of the compound.
+func (t Compound) Set(key String, value NbtTag) {
+	entry, ok := t[key]
+	if !ok {
+		entry.Index = len(t)
+	}
+	entry.Value = value
+	t[key] = entry
+}
+
+// Delete removes key from the compound and moves all following keys up by one position. Deleting
+// a missing key is a no-op.
+func (t Compound) Delete(key String) {
+	entry, ok := t[key]
+	if !ok {
+		return
+	}
+	delete(t, key)
+	for k, v := range t {
+		if v.Index > entry.Index {
+			v.Index--
+			t[k] = v
+		}
+	}
+}
+
 func (t Compound) MarshalJSON() ([]byte, error) {
 	return t.marshalJSON(false)
 }
@@ -367,15 +462,7 @@ func (t Compound) marshalJSON(njson bool) (b []byte, err error) {
 type IntArray []Int
 
 func (t IntArray) String() string {
-	var itemsString string
-	for i, item := range t {
-		if i == 0 {
-			itemsString = fmt.Sprint(item)
-		} else {
-			itemsString = itemsString + ", " + fmt.Sprint(item)
-		}
-	}
-	return fmt.Sprintf("[I; %s]", itemsString)
+	return arrayString("I", t)
 }
 
 func (t IntArray) Type() TagType {
@@ -389,15 +476,7 @@ func (t IntArray) MarshalJSON() ([]byte, error) {
 type LongArray []Long
 
 func (t LongArray) String() string {
-	var itemsString string
-	for i, item := range t {
-		if i == 0 {
-			itemsString = fmt.Sprint(item)
-		} else {
-			itemsString = itemsString + ", " + fmt.Sprint(item)
-		}
-	}
-	return fmt.Sprintf("[L; %s]", itemsString)
+	return arrayString("L", t)
 }
 
 func (t LongArray) Type() TagType {
@@ -408,6 +487,39 @@ func (t LongArray) MarshalJSON() ([]byte, error) {
 	return arrayMarshalJSON([]Long(t))
 }
 
+// writeString writes the string of tag at the given depth to sb. Compounds and lists write their
+// children to the same builder, so the string of a tree is built at once. The depth is passed
+// along instead of kept globally, so trees can be printed concurrently.
+func writeString(sb *strings.Builder, tag NbtTag, depth int) {
+	switch t := tag.(type) {
+	case Compound:
+		t.writeString(sb, depth)
+	case List:
+		t.writeString(sb, depth)
+	default:
+		sb.WriteString(tag.String())
+	}
+}
+
+// arrayString returns the string of an array with the type prefix, e.g. "[I; 1, 2, 3]".
+func arrayString[T interface {
+	Byte | Int | Long
+	fmt.Stringer
+}](prefix string, items []T) string {
+	var sb strings.Builder
+	sb.WriteByte('[')
+	sb.WriteString(prefix)
+	sb.WriteString("; ")
+	for i, item := range items {
+		if i != 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(item.String())
+	}
+	sb.WriteByte(']')
+	return sb.String()
+}
+
 // numberToJSON is a helper function that formats any NBT number to a valid JSON number.
 func numberToJSON[N Byte | Short | Int | Long | Float | Double](num N) ([]byte, error) {
 	_, okF := any(num).(Float)
@@ -442,3 +554,30 @@ func arrayMarshalJSON[S []E, E NbtTag](s S) ([]byte, error) {
 	childsBytes.WriteByte(']')
 	return childsBytes.Bytes(), err
 }
+
+// Clone returns a deep copy of tag, that shares no compounds, lists or arrays with the original.
+func Clone(tag NbtTag) NbtTag {
+	switch t := tag.(type) {
+	case Compound:
+		c := make(Compound, len(t))
+		for k, v := range t {
+			v.Value = Clone(v.Value)
+			c[k] = v
+		}
+		return c
+	case List:
+		l := List{TagType: t.TagType, Elements: make([]NbtTag, len(t.Elements))}
+		for i, entry := range t.Elements {
+			l.Elements[i] = Clone(entry)
+		}
+		return l
+	case ByteArray:
+		return slices.Clone(t)
+	case IntArray:
+		return slices.Clone(t)
+	case LongArray:
+		return slices.Clone(t)
+	default:
+		return tag
+	}
+}
@@ -0,0 +1,89 @@
+package nbtreader
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+)
+
+const (
+	// MaxDepth is the maximum nesting depth of compounds and lists, that Minecraft accepts.
+	MaxDepth = 512
+	// MaxStringLength is the maximum length of a string in bytes of Modified UTF-8, as its length
+	// is stored in an unsigned 16 bit integer.
+	MaxStringLength = math.MaxUint16
+	// MaxArrayLength is the maximum number of elements in a list or array, as its length is stored
+	// in a signed 32 bit integer.
+	MaxArrayLength = math.MaxInt32
+)
+
+// Validate checks that tree can be composed to valid NBT data. It reports nil tags, compounds with
+// entries, whose Index is not a distinct position from 0 to n-1, lists with elements of another
+// type than the list type, strings, lists and arrays, that exceed their length limits, and trees
+// nested deeper than [MaxDepth].
+//
+// All problems are returned joined together, each as a [Violation] with the path of the invalid
+// tag.
+func Validate(tree NbtTag) error {
+	var violations []error
+	validateTag(&violations, Path{}, tree, 0)
+	return errors.Join(violations...)
+}
+
+// validateTag checks tag at path p. The children are checked with p extended in place, so p is
+// only copied for violations.
+func validateTag(violations *[]error, p Path, tag NbtTag, depth int) {
+	addf := func(format string, a ...any) {
+		*violations = append(*violations, Violation{Path: slices.Clone(p), Message: fmt.Sprintf(format, a...)})
+	}
+
+	if tag == nil {
+		addf("nil tag")
+		return
+	}
+
+	switch t := tag.(type) {
+	case Compound:
+		if depth >= MaxDepth {
+			addf("exceeds maximum depth of %d", MaxDepth)
+			return
+		}
+		if err := t.checkIndices(); err != nil {
+			addf("%v", err)
+			return
+		}
+		for _, comp := range t.getOrdered() {
+			if n := mutf8Len(string(comp.Key)); n > MaxStringLength {
+				*violations = append(*violations, Violation{Path: p.Key(comp.Key), Message: fmt.Sprintf("key length %d exceeds maximum of %d bytes", n, MaxStringLength)})
+			}
+			validateTag(violations, append(p, PathElement{Key: comp.Key}), comp.Value, depth+1)
+		}
+	case List:
+		if depth >= MaxDepth {
+			addf("exceeds maximum depth of %d", MaxDepth)
+			return
+		}
+		if len(t.Elements) > MaxArrayLength {
+			addf("length %d exceeds maximum of %d elements", len(t.Elements), MaxArrayLength)
+		}
+		if len(t.Elements) > 0 && t.TagType == Tag_End {
+			addf("list of type %s cannot have elements", t.TagType)
+		}
+		for i, entry := range t.Elements {
+			if entry != nil && entry.Type() != t.TagType {
+				*violations = append(*violations, Violation{Path: p.Index(i), Message: fmt.Sprintf("element of type %s in list of %s", entry.Type(), t.TagType)})
+				continue
+			}
+			validateTag(violations, append(p, PathElement{Index: i, IsIndex: true}), entry, depth+1)
+		}
+	case String:
+		if n := mutf8Len(string(t)); n > MaxStringLength {
+			addf("length %d exceeds maximum of %d bytes", n, MaxStringLength)
+		}
+	case ByteArray, IntArray, LongArray:
+		if n := elementCount(t); n > MaxArrayLength {
+			addf("length %d exceeds maximum of %d elements", n, MaxArrayLength)
+		}
+	}
+}
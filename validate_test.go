@@ -0,0 +1,36 @@
+package nbtreader
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestValidateCompoundIndices(t *testing.T) {
+	tests := map[string]Compound{
+		"out of range": {"a": {Index: 5, Value: Int(1)}},
+		"negative":     {"a": {Index: -1, Value: Int(1)}},
+		"used twice":   {"a": {Value: Int(1)}, "b": {Value: Int(2)}},
+	}
+	for name, tree := range tests {
+		t.Run(name, func(t *testing.T) {
+			root := Compound{}
+			root.Set("c", tree)
+
+			var violation Violation
+			if err := Validate(root); !errors.As(err, &violation) {
+				t.Fatalf("Validate returned %v, want a violation", err)
+			} else if violation.Path.String() != "c" {
+				t.Errorf("violation at %q, want c", violation.Path)
+			}
+
+			nbt, err := FromRoot(root, io.Discard, SkipValidation())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = nbt.Compose(NONE); err == nil {
+				t.Error("composing the invalid tree succeeded")
+			}
+		})
+	}
+}